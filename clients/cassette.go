@@ -0,0 +1,307 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// cassetteFile is the name of the file interactions are recorded to within the --record or --replay directory
+const cassetteFile = "cassette.json"
+
+// redacted replaces scrubbed secrets in recorded interactions
+const redacted = "REDACTED"
+
+// sensitiveKeys are JSON fields and query parameters whose values are scrubbed from recordings
+var sensitiveKeys = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"token":         true,
+	"api_token":     true,
+	"client_secret": true,
+	"secret":        true,
+	"password":      true,
+}
+
+// keptRequestHeaders are the only request headers recorded. Everything else, including credentials, is dropped.
+var keptRequestHeaders = []string{"Content-Type", "Accept"}
+
+// droppedResponseHeaders are not recorded
+var droppedResponseHeaders = []string{"Set-Cookie", "Authorization"}
+
+// CassetteTransport records every request and response that passes through it to a cassette, or when replaying,
+// answers requests from a cassette without touching the network
+type CassetteTransport struct {
+	Next     http.RoundTripper // unused when replaying
+	cassette *cassette
+	replay   bool
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"` // path and query only, so a cassette replays against any host
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// cassette is the set of interactions recorded in a directory. Clients for several profiles can share one.
+type cassette struct {
+	path         string
+	mutex        sync.Mutex
+	Interactions []interaction `json:"interactions"`
+	played       map[int]bool
+}
+
+var (
+	cassettesMutex sync.Mutex
+	cassettes      = map[string]*cassette{}
+)
+
+// NewRecorder returns a transport that records everything sent through next to a new cassette in dir,
+// replacing any recorded there before
+func NewRecorder(dir string, next http.RoundTripper) (*CassetteTransport, error) {
+	c, err := openCassette(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	return &CassetteTransport{Next: next, cassette: c}, nil
+}
+
+// NewReplayer returns a transport that answers requests with the responses recorded in dir
+func NewReplayer(dir string) (*CassetteTransport, error) {
+	c, err := openCassette(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	return &CassetteTransport{cassette: c, replay: true}, nil
+}
+
+func openCassette(dir string, replay bool) (*cassette, error) {
+	path, err := filepath.Abs(filepath.Join(dir, cassetteFile))
+	if err != nil {
+		return nil, err
+	}
+	cassettesMutex.Lock()
+	defer cassettesMutex.Unlock()
+	if c, ok := cassettes[path]; ok {
+		return c, nil
+	}
+	c := &cassette{path: path, played: map[int]bool{}}
+	if replay {
+		// #nosec G304 the cassette is named by the user
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("unable to read cassette %s: %s", path, err)
+		}
+	} else {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return nil, err
+		}
+		if err := c.save(); err != nil {
+			return nil, err
+		}
+	}
+	cassettes[path] = c
+	return c, nil
+}
+
+func (t *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.replay {
+		return t.cassette.play(req)
+	}
+	reqBody, err := peekRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	err = t.cassette.record(interaction{
+		Request: recordedRequest{
+			Method: req.Method,
+			URL:    scrubURL(req.URL),
+			Header: filterHeader(req.Header, keptRequestHeaders, nil),
+			Body:   scrubBody(req.URL, reqBody),
+		},
+		Response: recordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     filterHeader(resp.Header, nil, droppedResponseHeaders),
+			Body:       scrubBody(req.URL, respBody),
+		},
+	})
+	return resp, err
+}
+
+// peekRequestBody returns the request body without consuming it
+func peekRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return body, err
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// record appends an interaction and saves the cassette straight away, so nothing is lost if the command exits early
+func (c *cassette) record(i interaction) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Interactions = append(c.Interactions, i)
+	return c.save()
+}
+
+func (c *cassette) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, data, 0600)
+}
+
+// play answers the request with the first recorded interaction for the same method and URL that hasn't been
+// played yet, so repeated requests (e.g. paging) get their responses in the order they were recorded
+func (c *cassette) play(req *http.Request) (*http.Response, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	u := scrubURL(req.URL)
+	for n, i := range c.Interactions {
+		if c.played[n] || i.Request.Method != req.Method || i.Request.URL != u {
+			continue
+		}
+		c.played[n] = true
+		header := i.Response.Header
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+			StatusCode:    i.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(strings.NewReader(i.Response.Body)),
+			ContentLength: int64(len(i.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, u, c.path)
+}
+
+// scrubURL returns the request path and query with sensitive query parameters redacted
+func scrubURL(u *url.URL) string {
+	query := u.Query()
+	for key := range query {
+		if sensitiveKeys[strings.ToLower(key)] {
+			query.Set(key, redacted)
+		}
+	}
+	out := url.URL{Path: u.Path, RawQuery: query.Encode()}
+	return out.String()
+}
+
+// scrubBody redacts sensitive fields from JSON bodies. Smart hook environment variable values are secrets too.
+// Bodies that aren't JSON are recorded as they are.
+func scrubBody(u *url.URL, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // keeps large ids intact
+	if err := decoder.Decode(&data); err != nil {
+		return string(body)
+	}
+	keys := sensitiveKeys
+	if strings.Contains(u.Path, "/hooks/envs") {
+		keys = map[string]bool{"value": true}
+		for k := range sensitiveKeys {
+			keys[k] = true
+		}
+	}
+	scrubbed, _ := json.Marshal(scrubValue(data, keys))
+	return string(scrubbed)
+}
+
+func scrubValue(data interface{}, keys map[string]bool) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if _, isString := value.(string); isString && keys[strings.ToLower(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = scrubValue(value, keys)
+			}
+		}
+	case []interface{}:
+		for n, value := range v {
+			v[n] = scrubValue(value, keys)
+		}
+	}
+	return data
+}
+
+// filterHeader copies the header keeping only the named keys if keep is given, and dropping those in drop
+func filterHeader(header http.Header, keep, drop []string) http.Header {
+	out := http.Header{}
+	for key, values := range header {
+		if keep != nil && !containsHeader(keep, key) {
+			continue
+		}
+		if containsHeader(drop, key) {
+			continue
+		}
+		out[key] = values
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func containsHeader(names []string, key string) bool {
+	for _, name := range names {
+		if http.CanonicalHeaderKey(name) == http.CanonicalHeaderKey(key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package clients
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenPath:
+			w.Write([]byte(`{"access_token":"live-access-token","expires_in":36000}`))
+		case "/api/2/hooks/envs":
+			w.Write([]byte(`[{"id":"1","name":"FOO","value":"live-env-value"}]`))
+		default:
+			w.Header().Set("Set-Cookie", "session=live-cookie")
+			w.Write([]byte(`[{"id":12345678901234,"name":"` + r.URL.Query().Get("page") + `"}]`))
+		}
+	}))
+	defer server.Close()
+
+	recorder, err := NewRecorder(dir, http.DefaultTransport)
+	assert.Nil(t, err)
+	client := &http.Client{Transport: recorder}
+	live := []string{}
+	requests := []*http.Request{}
+	for _, path := range []string{tokenPath, "/api/2/apps?page=1", "/api/2/apps?page=2", "/api/2/apps?page=1", "/api/2/hooks/envs"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if path == tokenPath {
+			req, _ = http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(`{"grant_type":"client_credentials","client_secret":"live-client-secret"}`))
+		}
+		req.SetBasicAuth("id", "live-basic-auth")
+		requests = append(requests, req)
+		resp, err := client.Do(req)
+		assert.Nil(t, err)
+		data, _ := ioutil.ReadAll(resp.Body)
+		live = append(live, string(data))
+	}
+
+	content, _ := ioutil.ReadFile(filepath.Join(dir, cassetteFile))
+	for _, secret := range []string{"live-access-token", "live-client-secret", "live-basic-auth", "bGl2ZS1iYXNpYy1hdXRo", "live-cookie", "live-env-value"} {
+		assert.NotContains(t, string(content), secret)
+	}
+	assert.Contains(t, string(content), "12345678901234", "ids should be recorded exactly")
+
+	absPath, _ := filepath.Abs(filepath.Join(dir, cassetteFile))
+	delete(cassettes, absPath) // replay from disk rather than the recording in memory
+	replayer, err := NewReplayer(dir)
+	assert.Nil(t, err)
+	server.Close() // replays must not touch the network
+	client = &http.Client{Transport: replayer}
+	for n, req := range requests {
+		req.URL, _ = url.Parse("http://replay.invalid" + req.URL.RequestURI())
+		if req.GetBody != nil {
+			req.Body, _ = req.GetBody()
+		}
+		resp, err := client.Do(req)
+		assert.Nil(t, err)
+		data, _ := ioutil.ReadAll(resp.Body)
+		if n == 0 || n == 4 {
+			assert.NotEqual(t, live[n], string(data), "secrets should be replayed redacted")
+			assert.Contains(t, string(data), redacted)
+		} else {
+			assert.JSONEq(t, live[n], string(data), "request %d", n)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://replay.invalid/api/2/apps?page=1", nil)
+	_, err = client.Do(req)
+	assert.Error(t, err, "each recorded response is only replayed once")
+}
+
+func TestScrubBody(t *testing.T) {
+	tests := map[string]struct {
+		Path, Body, Expected string
+		NotJSON              bool
+	}{
+		"It redacts nested secrets": {
+			Path:     "/api/2/apps",
+			Body:     `{"name":"app","configuration":{"client_secret":"s","token":"t"},"users":[{"password":"p"}]}`,
+			Expected: `{"name":"app","configuration":{"client_secret":"REDACTED","token":"REDACTED"},"users":[{"password":"REDACTED"}]}`,
+		},
+		"It redacts smart hook environment variable values": {
+			Path:     "/api/2/hooks/envs/1",
+			Body:     `{"name":"FOO","value":"bar"}`,
+			Expected: `{"name":"FOO","value":"REDACTED"}`,
+		},
+		"It leaves values elsewhere alone": {
+			Path:     "/api/2/apps",
+			Body:     `{"parameters":{"value":"bar"}}`,
+			Expected: `{"parameters":{"value":"bar"}}`,
+		},
+		"It records bodies that are not JSON as they are": {
+			Path:     "/api/2/apps",
+			Body:     `not json`,
+			Expected: `not json`,
+			NotJSON:  true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			scrubbed := scrubBody(&url.URL{Path: test.Path}, []byte(test.Body))
+			if test.NotJSON {
+				assert.Equal(t, test.Expected, scrubbed)
+			} else {
+				assert.JSONEq(t, test.Expected, scrubbed)
+			}
+		})
+	}
+}
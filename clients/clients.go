@@ -10,15 +10,20 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/okta/okta-sdk-golang/v2/okta"
 	"github.com/onelogin/onelogin-go-sdk/pkg/client"
+	"github.com/onelogin/onelogin-go-sdk/pkg/services/olhttp"
 	"github.com/onelogin/onelogin/profiles"
 )
 
@@ -27,22 +32,61 @@ type Clients struct {
 	OneLogin *client.APIClient
 	AwsIam   *iam.IAM
 	Okta     *okta.Client
+	// Tokens caches OneLogin access tokens between invocations. Nil disables caching.
+	Tokens *TokenCache
+	// Debug traces every request made to OneLogin, Okta and AWS when set
+	Debug         *log.Logger
+	httpTransport *http.Transport
+	httpClient    *http.Client
 	ClientConfigs
 }
 
 type ClientConfigs struct {
-	AwsRegion                                           string
+	ProfileName                                         string
+	AwsRegion, AwsProfile                               string
 	OneLoginClientID, OneLoginClientSecret, OneLoginURL string
 	OktaOrgName, OktaBaseURL, OktaAPIToken              string
+	Timeout                                             time.Duration // per attempt. Zero waits indefinitely
+	MaxRetries                                          int
+	RecordDir, ReplayDir                                string // record OneLogin and Okta traffic to, or replay it from, a cassette in this directory
+	Proxy                                               string // proxy URL for every client. Empty uses HTTPS_PROXY and friends
+	CABundle                                            string // PEM file of extra trusted certificates, e.g. a TLS inspecting proxy's
+	ClientCert, ClientKey                               string // PEM files presented for mutual TLS
 }
 
-func New(credsFile *os.File) *Clients {
+// TimeoutEnvVar and MaxRetriesEnvVar name the environment variables that set the HTTP timeout in seconds
+// and the retry limit when the profile doesn't
+const (
+	TimeoutEnvVar    = "ONELOGIN_TIMEOUT"
+	MaxRetriesEnvVar = "ONELOGIN_MAX_RETRIES"
+)
+
+// New resolves the OneLogin credentials for this invocation and returns an empty client list.
+// Credentials come from, in order of precedence, the profile named by profileName,
+// the active profile, or the ONELOGIN_CLIENT_ID, ONELOGIN_CLIENT_SECRET and ONELOGIN_OAPI_URL environment variables.
+func New(credsFile *os.File, profileName string) *Clients {
 	profileService := profiles.ProfileService{
-		Repository: profiles.FileRepository{
-			StorageMedia: credsFile,
-		},
+		Repository: profiles.OpenRepository(credsFile),
+	}
+	clientList := ForProfile(profileService.Select(profileName))
+	if profileService.Encrypted() {
+		clientList.Tokens = nil // the token cache is plaintext, so it isn't used alongside encrypted profiles
+	}
+	return clientList
+}
+
+// ForProfile resolves the credentials for the given profile and returns an empty client list.
+// A nil profile authenticates with the ONELOGIN_CLIENT_ID, ONELOGIN_CLIENT_SECRET and ONELOGIN_OAPI_URL environment variables.
+func ForProfile(profile *profiles.Profile) *Clients {
+	clientList, err := LoadProfile(profile)
+	if err != nil {
+		log.Fatalln(err)
 	}
-	profile := profileService.GetActive()
+	return clientList
+}
+
+// LoadProfile is ForProfile for callers that handle a profile's bad settings or failing credential helper themselves
+func LoadProfile(profile *profiles.Profile) (*Clients, error) {
 	clientConfigs := ClientConfigs{
 		AwsRegion:            os.Getenv("AWS_REGION"),
 		OktaOrgName:          os.Getenv("OKTA_ORG_NAME"),
@@ -51,69 +95,322 @@ func New(credsFile *os.File) *Clients {
 		OneLoginClientID:     os.Getenv("ONELOGIN_CLIENT_ID"),
 		OneLoginClientSecret: os.Getenv("ONELOGIN_CLIENT_SECRET"),
 		OneLoginURL:          os.Getenv("ONELOGIN_OAPI_URL"),
+		Timeout:              DefaultTimeout,
+		MaxRetries:           DefaultMaxRetries,
 	}
+	if timeout := os.Getenv(TimeoutEnvVar); timeout != "" {
+		seconds, err := strconv.Atoi(timeout)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("%s must be a number of seconds", TimeoutEnvVar)
+		}
+		clientConfigs.Timeout = time.Duration(seconds) * time.Second
+	}
+	if retries := os.Getenv(MaxRetriesEnvVar); retries != "" {
+		n, err := strconv.Atoi(retries)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s must be a whole number", MaxRetriesEnvVar)
+		}
+		clientConfigs.MaxRetries = n
+	}
+	if profile != nil {
+		clientConfigs.applySources(*profile)
+	}
+	// status goes to stderr so commands like env can print machine readable output
 	if profile == nil {
-		fmt.Println("No active profile detected. Authenticating with environment variables")
+		fmt.Fprintln(os.Stderr, "No active profile detected. Authenticating with environment variables")
 	} else {
-		fmt.Println("Using profile", (*profile).Name)
+		fmt.Fprintln(os.Stderr, "Using profile", (*profile).Name)
+		clientConfigs.ProfileName = (*profile).Name
 		clientConfigs.OneLoginClientID = (*profile).ClientID
 		clientConfigs.OneLoginClientSecret = (*profile).ClientSecret
-		clientConfigs.OneLoginURL = fmt.Sprintf("https://api.%s.onelogin.com", (*profile).Region)
+		if (*profile).CredentialHelper != "" {
+			creds, err := runCredentialHelper(*profile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get credentials from credential helper: %s", err)
+			}
+			clientConfigs.OneLoginClientID = creds.ClientID
+			clientConfigs.OneLoginClientSecret = creds.ClientSecret
+		}
+		clientConfigs.OneLoginURL = (*profile).BaseURL()
+	}
+	return &Clients{ClientConfigs: clientConfigs, Tokens: DefaultTokenCache()}, nil
+}
+
+// String formats the configuration with its secrets masked so it can be logged
+func (c ClientConfigs) String() string {
+	c.OneLoginClientSecret = profiles.Redact(c.OneLoginClientSecret)
+	c.OktaAPIToken = profiles.Redact(c.OktaAPIToken)
+	c.Proxy = profiles.RedactURL(c.Proxy)
+	type redacted ClientConfigs // drops the methods so formatting doesn't recurse back into String
+	return fmt.Sprintf("%+v", redacted(c))
+}
+
+// GoString keeps the secrets masked when formatted with %#v
+func (c ClientConfigs) GoString() string {
+	return c.String()
+}
+
+// Environ returns the resolved configuration as the environment variables New reads it from, in KEY=value form,
+// for handing to tools such as the OneLogin Terraform provider. Unset values are left out.
+func (c ClientConfigs) Environ() []string {
+	vars := [][2]string{
+		{"ONELOGIN_CLIENT_ID", c.OneLoginClientID},
+		{"ONELOGIN_CLIENT_SECRET", c.OneLoginClientSecret},
+		{"ONELOGIN_OAPI_URL", c.OneLoginURL},
+		{"OKTA_ORG_NAME", c.OktaOrgName},
+		{"OKTA_BASE_URL", c.OktaBaseURL},
+		{"OKTA_API_TOKEN", c.OktaAPIToken},
+		{"AWS_REGION", c.AwsRegion},
+		{"AWS_PROFILE", c.AwsProfile},
+	}
+	env := []string{}
+	for _, v := range vars {
+		if v[1] != "" {
+			env = append(env, v[0]+"="+v[1])
+		}
+	}
+	return env
+}
+
+// applySources overrides the Okta, AWS and HTTP settings taken from the environment with any the profile defines
+func (c *ClientConfigs) applySources(profile profiles.Profile) {
+	if profile.Okta != nil {
+		if profile.Okta.OrgName != "" {
+			c.OktaOrgName = profile.Okta.OrgName
+		}
+		if profile.Okta.BaseURL != "" {
+			c.OktaBaseURL = profile.Okta.BaseURL
+		}
+		if profile.Okta.APIToken != "" {
+			c.OktaAPIToken = profile.Okta.APIToken
+		}
+	}
+	if profile.HTTP != nil {
+		if profile.HTTP.Timeout != 0 {
+			c.Timeout = time.Duration(profile.HTTP.Timeout) * time.Second
+		}
+		if profile.HTTP.MaxRetries != nil {
+			c.MaxRetries = *profile.HTTP.MaxRetries
+		}
+		if profile.HTTP.Proxy != "" {
+			c.Proxy = profile.HTTP.Proxy
+		}
+		if profile.HTTP.CABundle != "" {
+			c.CABundle = profile.HTTP.CABundle
+		}
+		if profile.HTTP.ClientCert != "" {
+			c.ClientCert, c.ClientKey = profile.HTTP.ClientCert, profile.HTTP.ClientKey
+		}
+	}
+	if profile.AWS != nil {
+		if profile.AWS.Region != "" {
+			c.AwsRegion = profile.AWS.Region
+		}
+		if profile.AWS.Profile != "" {
+			c.AwsProfile = profile.AWS.Profile
+		}
+	}
+}
+
+// HTTPTransport creates and returns the connection pool shared by the remote clients if one does not exist.
+// It applies the configured timeout to each attempt, and the configured proxy, CA bundle and client certificate.
+func (c *Clients) HTTPTransport() *http.Transport {
+	transport, err := c.loadHTTPTransport()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return transport
+}
+
+func (c *Clients) loadHTTPTransport() (*http.Transport, error) {
+	if c.httpTransport == nil {
+		transport, err := newTransport(c.ClientConfigs)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure HTTP connections: %s", err)
+		}
+		c.httpTransport = transport
 	}
-	return &Clients{ClientConfigs: clientConfigs}
+	return c.httpTransport, nil
 }
 
+// HTTPClient creates and returns the HTTP client shared by the OneLogin and Okta clients if one does not exist.
+// It retries rate limited and transiently failing requests over the shared transport, traces each attempt if
+// Debug is set, and records the outcome of each request to a cassette or answers it from one if RecordDir or
+// ReplayDir is set.
+func (c *Clients) HTTPClient() *http.Client {
+	httpClient, err := c.loadHTTPClient()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return httpClient
+}
+
+func (c *Clients) loadHTTPClient() (*http.Client, error) {
+	if c.httpClient == nil {
+		sharedTransport, err := c.loadHTTPTransport()
+		if err != nil {
+			return nil, err
+		}
+		var transport http.RoundTripper = sharedTransport
+		if c.ClientConfigs.ReplayDir != "" {
+			replayer, err := NewReplayer(c.ClientConfigs.ReplayDir)
+			if err != nil {
+				return nil, fmt.Errorf("unable to open cassette: %s", err)
+			}
+			transport = replayer
+		}
+		if c.Debug != nil {
+			transport = &DebugTransport{Next: transport, Log: c.Debug}
+		}
+		if c.ClientConfigs.ReplayDir == "" {
+			transport = &RetryTransport{Next: transport, MaxRetries: c.ClientConfigs.MaxRetries}
+		}
+		if c.ClientConfigs.RecordDir != "" {
+			recorder, err := NewRecorder(c.ClientConfigs.RecordDir, transport)
+			if err != nil {
+				return nil, fmt.Errorf("unable to open cassette: %s", err)
+			}
+			transport = recorder
+		}
+		c.httpClient = &http.Client{Transport: transport}
+	}
+	return c.httpClient, nil
+}
+
+// OktaClient creates and returns an instance of the Okta API client if one does not exist
+// Memoizes the Okta API client and returns that instance on every subsequent call
 func (c *Clients) OktaClient() *okta.Client {
+	oktaClient, err := c.LoadOktaClient()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return oktaClient
+}
+
+// LoadOktaClient is OktaClient for callers that handle a misconfigured client themselves
+func (c *Clients) LoadOktaClient() (*okta.Client, error) {
 	if c.Okta == nil {
+		httpClient, err := c.loadHTTPClient()
+		if err != nil {
+			return nil, err
+		}
 		oktaURL := fmt.Sprintf("https://%s.%s", c.ClientConfigs.OktaOrgName, c.ClientConfigs.OktaBaseURL)
 		_, oktaClient, err := okta.NewClient(
 			context.TODO(),
 			okta.WithOrgUrl(oktaURL),
 			okta.WithToken(c.ClientConfigs.OktaAPIToken),
+			okta.WithHttpClient(*httpClient),
+			okta.WithRateLimitMaxRetries(0), // retries are handled by the shared HTTP client
 		)
 		if err != nil {
-			log.Fatalln("There was a problem configuring the Okta client. Ensure your Okta credentials are exported to your environment", err)
-		} else {
-			c.Okta = oktaClient
+			return nil, fmt.Errorf("there was a problem configuring the Okta client. Ensure your Okta credentials are exported to your environment: %s", err)
 		}
+		c.Okta = oktaClient
 	}
-	return c.Okta
+	return c.Okta, nil
 }
 
 // OneLoginClient creates and returns an instance of the OneLogin API client if one does not exist
 // Memoizes the OneLogin API client and returns that instance on every subsequent call
 func (c *Clients) OneLoginClient() *client.APIClient {
+	oneloginClient, err := c.LoadOneLoginClient()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return oneloginClient
+}
+
+// LoadOneLoginClient is OneLoginClient for callers that handle a misconfigured client themselves
+func (c *Clients) LoadOneLoginClient() (*client.APIClient, error) {
 	if c.OneLogin == nil {
+		httpClient, err := c.loadHTTPClient()
+		if err != nil {
+			return nil, err
+		}
 		clientConfig := &client.APIClientConfig{
-			Timeout:      5,
 			ClientID:     c.ClientConfigs.OneLoginClientID,
 			ClientSecret: c.ClientConfigs.OneLoginClientSecret,
 			Url:          c.ClientConfigs.OneLoginURL,
 		}
 		oneloginClient, err := client.NewClient(clientConfig)
 		if err != nil {
-			log.Fatalln("There was a problem configuring the OneLogin client. Ensure your OneLogin credentials are exported to your environment", err)
-		} else {
-			c.OneLogin = oneloginClient
+			return nil, fmt.Errorf("there was a problem configuring the OneLogin client. Ensure your OneLogin credentials are exported to your environment: %s", err)
+		}
+		oneloginClient.Services.HTTPService.Config.Client = httpClient
+		// cached tokens would keep token exchanges out of recordings, and replayed tokens are redacted
+		if c.Tokens != nil && c.ClientConfigs.RecordDir == "" && c.ClientConfigs.ReplayDir == "" {
+			c.useTokenCache(oneloginClient.Services.HTTPService)
 		}
+		c.OneLogin = oneloginClient
+	}
+	return c.OneLogin, nil
+}
+
+// useTokenCache seeds the OneLogin HTTP service with a cached access token, if one is still valid,
+// and caches any token the service mints in its place
+func (c *Clients) useTokenCache(svc *olhttp.OLHTTPService) {
+	key := c.ClientConfigs.ProfileName
+	if key == "" {
+		key = environmentCacheKey
+	}
+	if token, ok := c.Tokens.Get(key, c.ClientConfigs.OneLoginClientID, c.ClientConfigs.OneLoginURL); ok {
+		svc.ClientCredential = olhttp.ClientCredential{AccessToken: &token}
+	}
+	svc.Config.Client = tokenCapture{
+		next:     svc.Config.Client,
+		cache:    c.Tokens,
+		profile:  key,
+		clientID: c.ClientConfigs.OneLoginClientID,
+		url:      c.ClientConfigs.OneLoginURL,
 	}
-	return c.OneLogin
 }
 
 // AwsIamClient creates and returns an instance of the AWS API client if one does not exist
 // Memoizes the AWS API client and returns that instance on every subsequent call
 func (c *Clients) AwsIamClient() *iam.IAM {
+	awsClient, err := c.LoadAwsIamClient()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return awsClient
+}
+
+// LoadAwsIamClient is AwsIamClient for callers that handle a misconfigured client themselves
+func (c *Clients) LoadAwsIamClient() (*iam.IAM, error) {
 	if c.AwsIam == nil {
-		sess, err := session.NewSession(
-			&aws.Config{
+		// AWS requests aren't recorded, so replaying would quietly reach the network instead
+		if c.ClientConfigs.ReplayDir != "" {
+			return nil, errors.New("AWS requests can't be replayed from a cassette. Run without --replay")
+		}
+		transport, err := c.loadHTTPTransport()
+		if err != nil {
+			return nil, err
+		}
+		opts := session.Options{
+			Config: aws.Config{
 				Region: aws.String(c.ClientConfigs.AwsRegion),
+				// the AWS SDK only accepts its own transport type, which it modifies to apply AWS_CA_BUNDLE,
+				// so it gets a copy of the shared transport and retries with its own throttling aware retryer
+				HTTPClient: &http.Client{Transport: transport.Clone()},
+				MaxRetries: aws.Int(c.ClientConfigs.MaxRetries),
 			},
-		)
+		}
+		if c.ClientConfigs.AwsProfile != "" {
+			// a named profile can carry its own region and role settings in ~/.aws/config
+			opts.Profile = c.ClientConfigs.AwsProfile
+			opts.SharedConfigState = session.SharedConfigEnable
+			if c.ClientConfigs.AwsRegion == "" {
+				opts.Config.Region = nil
+			}
+		}
+		sess, err := session.NewSessionWithOptions(opts)
 		if err != nil {
-			log.Fatalln("There was a problem configuring the AWS client. Ensure your AWS credentials are exported to your environment", err)
-		} else {
-			c.AwsIam = iam.New(sess)
+			return nil, fmt.Errorf("there was a problem configuring the AWS client. Ensure your AWS credentials are exported to your environment: %s", err)
+		}
+		if c.Debug != nil {
+			sess.Handlers.CompleteAttempt.PushBack(traceAWSRequest(c.Debug))
 		}
+		c.AwsIam = iam.New(sess)
 	}
-	return c.AwsIam
+	return c.AwsIam, nil
 }
@@ -0,0 +1,78 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/onelogin/onelogin/profiles"
+)
+
+// credentialHelperPrefix is prepended to bare helper names, so a profile with
+// credential_helper "pass" runs onelogin-credential-pass from the PATH
+const credentialHelperPrefix = "onelogin-credential-"
+
+// CredentialHelperRequest is written as JSON to the credential helper's stdin
+type CredentialHelperRequest struct {
+	Profile  string `json:"profile"`
+	Region   string `json:"region,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// CredentialHelperResponse is read as JSON from the credential helper's stdout.
+// A blank client_id falls back to the one stored in the profile.
+type CredentialHelperResponse struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// runCredentialHelper invokes the profile's credential helper with the "get" action and returns the
+// client credentials it yields. The helper's stderr is passed through so it can prompt the user.
+func runCredentialHelper(profile profiles.Profile) (CredentialHelperResponse, error) {
+	out := CredentialHelperResponse{}
+	args := strings.Fields(profile.CredentialHelper)
+	if len(args) == 0 {
+		return out, errors.New("no credential helper configured")
+	}
+	name := args[0]
+	// only paths run as given, so a bare name can't fall through to an unrelated command of the same name
+	if !strings.ContainsRune(name, os.PathSeparator) {
+		path, err := exec.LookPath(credentialHelperPrefix + name)
+		if err != nil {
+			return out, fmt.Errorf("credential helper %s%s not found on the PATH", credentialHelperPrefix, name)
+		}
+		name = path
+	}
+	input, err := json.Marshal(CredentialHelperRequest{
+		Profile:  profile.Name,
+		Region:   profile.Region,
+		ClientID: profile.ClientID,
+	})
+	if err != nil {
+		return out, err
+	}
+
+	var stdout bytes.Buffer
+	// #nosec G204 the helper is configured by the user in their own profile
+	cmd := exec.Command(name, append(args[1:], "get")...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return out, fmt.Errorf("credential helper %s failed: %s", name, err)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return out, fmt.Errorf("credential helper %s returned malformed output: %s", name, err)
+	}
+	if out.ClientID == "" {
+		out.ClientID = profile.ClientID
+	}
+	if out.ClientID == "" || out.ClientSecret == "" {
+		return out, fmt.Errorf("credential helper %s did not return a client_id and client_secret", name)
+	}
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package clients
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onelogin/onelogin/profiles"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFakeHelper creates an executable shell script that stands in for a credential helper
+func writeFakeHelper(t *testing.T, dir, name, script string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunCredentialHelper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helpers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// echoes the profile name it was asked about back as the secret so the request can be asserted on
+	echo := writeFakeHelper(t, dir, "echo-helper", `[ "$1" = "get" ] || exit 2
+profile=$(sed -e 's/.*"profile":"\([^"]*\)".*/\1/')
+printf '{"client_id":"helper-id","client_secret":"%s-secret"}' "$profile"
+`)
+	noID := writeFakeHelper(t, dir, "no-id-helper", `printf '{"client_secret":"helper-secret"}'`)
+	failing := writeFakeHelper(t, dir, "failing-helper", `echo "vault is sealed" >&2; exit 1`)
+	garbage := writeFakeHelper(t, dir, "garbage-helper", `echo "not json"`)
+	writeFakeHelper(t, dir, "onelogin-credential-prefixed", `printf '{"client_id":"prefixed-id","client_secret":"prefixed-secret"}'`)
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	defer os.Setenv("PATH", path)
+
+	tests := map[string]struct {
+		Profile       profiles.Profile
+		Expected      CredentialHelperResponse
+		ExpectedError bool
+	}{
+		"It reads credentials from the helper": {
+			Profile:  profiles.Profile{Name: "prod", CredentialHelper: echo},
+			Expected: CredentialHelperResponse{ClientID: "helper-id", ClientSecret: "prod-secret"},
+		},
+		"It falls back to the stored client id": {
+			Profile:  profiles.Profile{Name: "prod", ClientID: "stored-id", CredentialHelper: noID},
+			Expected: CredentialHelperResponse{ClientID: "stored-id", ClientSecret: "helper-secret"},
+		},
+		"It resolves bare helper names with the onelogin-credential- prefix": {
+			Profile:  profiles.Profile{Name: "prod", CredentialHelper: "prefixed"},
+			Expected: CredentialHelperResponse{ClientID: "prefixed-id", ClientSecret: "prefixed-secret"},
+		},
+		"It does not run bare names without the onelogin-credential- prefix": {
+			Profile:       profiles.Profile{Name: "prod", CredentialHelper: "echo-helper"},
+			ExpectedError: true,
+		},
+		"It errors when the helper fails": {
+			Profile:       profiles.Profile{Name: "prod", CredentialHelper: failing},
+			ExpectedError: true,
+		},
+		"It errors when the helper returns malformed output": {
+			Profile:       profiles.Profile{Name: "prod", CredentialHelper: garbage},
+			ExpectedError: true,
+		},
+		"It errors when the helper yields no client id": {
+			Profile:       profiles.Profile{Name: "prod", CredentialHelper: noID},
+			ExpectedError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := runCredentialHelper(test.Profile)
+			if test.ExpectedError {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, test.Expected, actual)
+			}
+		})
+	}
+}
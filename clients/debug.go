@@ -0,0 +1,92 @@
+package clients
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// maxDebugBody is how much of each request and response body is traced
+const maxDebugBody = 2048
+
+// requestIDHeaders are the headers OneLogin, Okta and AWS identify requests with, for quoting in support cases
+var requestIDHeaders = []string{"X-Request-Id", "X-Okta-Request-Id", "X-Amzn-Requestid", "X-Amz-Request-Id"}
+
+// DebugTransport logs every request that passes through it with its status, latency, request id
+// and bodies, with secrets scrubbed the same way as recordings
+type DebugTransport struct {
+	Next http.RoundTripper
+	Log  *log.Logger
+}
+
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := peekRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := t.Next.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+	target := debugURL(req.URL)
+	if err != nil {
+		t.Log.Printf("%s %s -> error after %s: %s", req.Method, target, latency, err)
+		return resp, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	if err != nil {
+		return nil, err
+	}
+	t.Log.Printf("%s %s -> %s (%s) request_id=%s", req.Method, target, resp.Status, latency, requestID(resp.Header))
+	if len(reqBody) > 0 {
+		t.Log.Printf("  request body: %s", truncate(scrubBody(req.URL, reqBody)))
+	}
+	if len(respBody) > 0 {
+		t.Log.Printf("  response body: %s", truncate(scrubBody(req.URL, respBody)))
+	}
+	return resp, nil
+}
+
+// traceAWSRequest returns an AWS SDK handler that logs each attempt in the same format as DebugTransport.
+// The AWS client can't go through DebugTransport, and its bodies are signed form posts and XML, so they aren't traced.
+func traceAWSRequest(logger *log.Logger) func(r *request.Request) {
+	return func(r *request.Request) {
+		latency := time.Since(r.AttemptTime).Round(time.Millisecond)
+		target := debugURL(r.HTTPRequest.URL)
+		if r.HTTPResponse == nil {
+			logger.Printf("%s %s (%s) -> error after %s: %s", r.HTTPRequest.Method, target, r.Operation.Name, latency, r.Error)
+			return
+		}
+		logger.Printf("%s %s (%s) -> %s (%s) request_id=%s", r.HTTPRequest.Method, target, r.Operation.Name, r.HTTPResponse.Status, latency, r.RequestID)
+		if r.Error != nil {
+			logger.Printf("  error: %s", r.Error)
+		}
+	}
+}
+
+// debugURL returns the full request URL with sensitive query parameters redacted
+func debugURL(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + scrubURL(u)
+}
+
+func requestID(header http.Header) string {
+	for _, name := range requestIDHeaders {
+		if id := header.Get(name); id != "" {
+			return id
+		}
+	}
+	return "none"
+}
+
+func truncate(body string) string {
+	if len(body) <= maxDebugBody {
+		return body
+	}
+	return body[:maxDebugBody] + "...(truncated)"
+}
@@ -0,0 +1,93 @@
+package clients
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-123")
+		if r.URL.Path == tokenPath {
+			w.Write([]byte(`{"access_token":"live-access-token"}`))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"Insufficient permissions"}`))
+	}))
+	defer server.Close()
+
+	out := bytes.Buffer{}
+	client := &http.Client{Transport: &DebugTransport{Next: http.DefaultTransport, Log: log.New(&out, "", 0)}}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+tokenPath, strings.NewReader(`{"grant_type":"client_credentials"}`))
+	req.SetBasicAuth("id", "live-secret")
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, `{"access_token":"live-access-token"}`, string(body), "the caller should still get the whole response")
+
+	resp, err = client.Get(server.URL + "/api/2/apps/1?access_token=live-query-token")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	trace := out.String()
+	assert.Contains(t, trace, "POST "+server.URL+tokenPath+" -> 200 OK")
+	assert.Contains(t, trace, "request_id=req-123")
+	assert.Contains(t, trace, `request body: {"grant_type":"client_credentials"}`)
+	assert.Contains(t, trace, "GET "+server.URL+"/api/2/apps/1?access_token=REDACTED -> 403 Forbidden")
+	assert.Contains(t, trace, `response body: {"message":"Insufficient permissions"}`)
+	for _, secret := range []string{"live-access-token", "live-secret", "live-query-token"} {
+		assert.NotContains(t, trace, secret)
+	}
+}
+
+func TestTraceAWSRequest(t *testing.T) {
+	tests := map[string]struct {
+		Response *http.Response
+		Error    error
+		Expected []string
+	}{
+		"It logs the status and request id": {
+			Response: &http.Response{Status: "200 OK", StatusCode: 200},
+			Expected: []string{"POST https://iam.amazonaws.com/ (ListUsers) -> 200 OK", "request_id=aws-123"},
+		},
+		"It logs API errors": {
+			Response: &http.Response{Status: "403 Forbidden", StatusCode: 403},
+			Error:    errors.New("AccessDenied"),
+			Expected: []string{"-> 403 Forbidden", "error: AccessDenied"},
+		},
+		"It logs transport errors": {
+			Error:    errors.New("connection refused"),
+			Expected: []string{"(ListUsers) -> error after", "connection refused"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			u, _ := url.Parse("https://iam.amazonaws.com/")
+			traceAWSRequest(log.New(&out, "", 0))(&request.Request{
+				Operation:    &request.Operation{Name: "ListUsers"},
+				HTTPRequest:  &http.Request{Method: http.MethodPost, URL: u},
+				HTTPResponse: test.Response,
+				Error:        test.Error,
+				RequestID:    "aws-123",
+				AttemptTime:  time.Now(),
+			})
+			for _, expected := range test.Expected {
+				assert.Contains(t, out.String(), expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/onelogin/onelogin-go-sdk/pkg/services"
+	"github.com/onelogin/onelogin-go-sdk/pkg/services/olhttp"
+)
+
+const (
+	// tokenRefreshWindow is how long before expiry a cached token stops being reused, so a fresh one
+	// is minted instead of risking expiry partway through a command
+	tokenRefreshWindow = 5 * time.Minute
+	// environmentCacheKey caches the token for credentials taken from environment variables rather than a profile
+	environmentCacheKey = "(environment)"
+)
+
+// TokenCache stores OneLogin access tokens per profile in a JSON file readable only by the user.
+// Concurrent writers may drop each other's entries, which only costs the loser a token exchange on its next run.
+type TokenCache struct {
+	Path string
+}
+
+// cachedToken is an access token along with the credentials and endpoint it was issued for,
+// so that editing a profile invalidates its token
+type cachedToken struct {
+	ClientID    string    `json:"client_id"`
+	URL         string    `json:"url"`
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// DefaultTokenCache returns the token cache kept at ~/.onelogin/tokens.json
+func DefaultTokenCache() *TokenCache {
+	home, err := homedir.Dir()
+	if err != nil {
+		log.Fatalln("Unable to locate home directory", err)
+	}
+	return &TokenCache{Path: filepath.Join(home, ".onelogin", "tokens.json")}
+}
+
+// Get returns the cached access token for the profile if it was issued for the given client id
+// and URL and is not about to expire
+func (c *TokenCache) Get(profile, clientID, url string) (string, bool) {
+	token, ok := c.read()[profile]
+	if !ok || token.ClientID != clientID || token.URL != url {
+		return "", false
+	}
+	if time.Now().Add(tokenRefreshWindow).After(token.ExpiresAt) {
+		return "", false
+	}
+	return token.AccessToken, true
+}
+
+// Put caches an access token for the profile
+func (c *TokenCache) Put(profile string, token cachedToken) error {
+	tokens := c.read()
+	tokens[profile] = token
+	return c.write(tokens)
+}
+
+// Forget removes the cached token for the given profiles, or every cached token if none are given
+func (c *TokenCache) Forget(profiles ...string) error {
+	if len(profiles) == 0 {
+		err := os.Remove(c.Path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	tokens := c.read()
+	for _, name := range profiles {
+		delete(tokens, name)
+	}
+	return c.write(tokens)
+}
+
+// read returns the cached tokens. A missing or unreadable cache is treated as empty.
+func (c *TokenCache) read() map[string]cachedToken {
+	tokens := map[string]cachedToken{}
+	data, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		return tokens
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return map[string]cachedToken{}
+	}
+	return tokens
+}
+
+func (c *TokenCache) write(tokens map[string]cachedToken) error {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0750); err != nil {
+		return err
+	}
+	// TempFile creates the file with 0600 permissions before it is renamed into place
+	tmp, err := ioutil.TempFile(filepath.Dir(c.Path), filepath.Base(c.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.Path)
+}
+
+// tokenCapture wraps the OneLogin HTTP client and caches every access token the SDK mints
+type tokenCapture struct {
+	next              services.HTTPClient
+	cache             *TokenCache
+	profile, clientID string
+	url               string
+}
+
+func (t tokenCapture) Do(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.HasSuffix(req.URL.Path, tokenPath) {
+		return resp, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	credential := olhttp.ClientCredential{}
+	if err := json.Unmarshal(data, &credential); err != nil || credential.AccessToken == nil || credential.ExpiresIn == nil {
+		return resp, nil
+	}
+	err = t.cache.Put(t.profile, cachedToken{
+		ClientID:    t.clientID,
+		URL:         t.url,
+		AccessToken: *credential.AccessToken,
+		ExpiresAt:   time.Now().Add(time.Duration(*credential.ExpiresIn) * time.Second),
+	})
+	if err != nil {
+		log.Println("Unable to cache access token", err)
+	}
+	return resp, nil
+}
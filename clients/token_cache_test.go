@@ -0,0 +1,127 @@
+package clients
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onelogin/onelogin-go-sdk/pkg/services/olhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func tempTokenCache(t *testing.T) *TokenCache {
+	dir, err := ioutil.TempDir("", "tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &TokenCache{Path: filepath.Join(dir, "tokens.json")}
+}
+
+func TestTokenCacheGet(t *testing.T) {
+	tests := map[string]struct {
+		Cached        cachedToken
+		ClientID, URL string
+		ExpectedToken string
+		ExpectedOK    bool
+	}{
+		"It returns unexpired tokens": {
+			Cached:        cachedToken{ClientID: "id", URL: "https://api.us.onelogin.com", AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour)},
+			ClientID:      "id",
+			URL:           "https://api.us.onelogin.com",
+			ExpectedToken: "token",
+			ExpectedOK:    true,
+		},
+		"It refreshes tokens that are about to expire": {
+			Cached:   cachedToken{ClientID: "id", URL: "https://api.us.onelogin.com", AccessToken: "token", ExpiresAt: time.Now().Add(time.Minute)},
+			ClientID: "id",
+			URL:      "https://api.us.onelogin.com",
+		},
+		"It ignores tokens issued to other credentials": {
+			Cached:   cachedToken{ClientID: "id", URL: "https://api.us.onelogin.com", AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour)},
+			ClientID: "other",
+			URL:      "https://api.us.onelogin.com",
+		},
+		"It ignores tokens issued by other endpoints": {
+			Cached:   cachedToken{ClientID: "id", URL: "https://api.us.onelogin.com", AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour)},
+			ClientID: "id",
+			URL:      "https://api.eu.onelogin.com",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cache := tempTokenCache(t)
+			defer os.RemoveAll(filepath.Dir(cache.Path))
+			assert.Nil(t, cache.Put("test", test.Cached))
+			token, ok := cache.Get("test", test.ClientID, test.URL)
+			assert.Equal(t, test.ExpectedOK, ok)
+			assert.Equal(t, test.ExpectedToken, token)
+		})
+	}
+}
+
+func TestTokenCacheForget(t *testing.T) {
+	cache := tempTokenCache(t)
+	defer os.RemoveAll(filepath.Dir(cache.Path))
+	token := cachedToken{ClientID: "id", URL: "u", AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour)}
+	cache.Put("a", token)
+	cache.Put("b", token)
+
+	info, err := os.Stat(cache.Path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.Nil(t, cache.Forget("a"))
+	_, ok := cache.Get("a", "id", "u")
+	assert.False(t, ok)
+	_, ok = cache.Get("b", "id", "u")
+	assert.True(t, ok)
+
+	assert.Nil(t, cache.Forget())
+	_, err = os.Stat(cache.Path)
+	assert.True(t, os.IsNotExist(err))
+	assert.Nil(t, cache.Forget(), "forgetting an empty cache is not an error")
+}
+
+func TestOneLoginClientTokenCache(t *testing.T) {
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == tokenPath {
+			tokenRequests++
+			w.Write([]byte(`{"access_token":"minted","expires_in":36000,"token_type":"bearer"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer minted" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+	cache := tempTokenCache(t)
+	defer os.RemoveAll(filepath.Dir(cache.Path))
+
+	for i := 0; i < 3; i++ {
+		clients := Clients{
+			Tokens: cache,
+			ClientConfigs: ClientConfigs{
+				ProfileName:          "test",
+				OneLoginClientID:     "test",
+				OneLoginClientSecret: "test",
+				OneLoginURL:          server.URL,
+			},
+		}
+		_, err := clients.OneLoginClient().Services.HTTPService.Read(olhttp.OLHTTPRequest{
+			URL:        server.URL + "/api/2/apps",
+			AuthMethod: "bearer",
+		})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 1, tokenRequests, "the token minted by the first client should be reused by the others")
+	token, ok := cache.Get("test", "test", server.URL)
+	assert.True(t, ok)
+	assert.Equal(t, "minted", token)
+}
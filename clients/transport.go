@@ -0,0 +1,201 @@
+package clients
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds how long each attempt waits for a response from a remote
+	DefaultTimeout = 30 * time.Second
+	// DefaultMaxRetries is how many times a failed request is retried before giving up
+	DefaultMaxRetries = 3
+
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 60 * time.Second
+)
+
+// newTransport returns a copy of the default transport that waits up to the configured timeout for each response
+// and connects through the configured proxy, trusting the CA bundle alongside the system roots and presenting the
+// client certificate if one is given
+func newTransport(c ClientConfigs) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = c.Timeout
+	if c.Proxy != "" {
+		proxyURL, err := url.Parse(c.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, errors.New("proxy must be an absolute URL e.g. http://proxy.example.com:3128")
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if c.CABundle == "" && c.ClientCert == "" && c.ClientKey == "" {
+		return transport, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		// #nosec G304 the CA bundle is named by the user
+		bundle, err := ioutil.ReadFile(c.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %s", err)
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", c.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.ClientCert != "" || c.ClientKey != "" {
+		if c.ClientCert == "" || c.ClientKey == "" {
+			return nil, errors.New("a client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// RetryTransport retries rate limited requests, and idempotent requests that fail with a transient
+// server or network error, waiting as long as the remote asks or else backing off exponentially with jitter
+type RetryTransport struct {
+	Next       http.RoundTripper
+	MaxRetries int
+
+	sleep func(req *http.Request, d time.Duration) error // swapped out in tests
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.Body != nil {
+			// RoundTrippers must not modify the caller's request, so each retry sends a copy with a fresh body
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+		resp, err := t.Next.RoundTrip(attemptReq)
+		if attempt >= t.MaxRetries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+		delay := backoff(attempt)
+		if resp != nil {
+			if wait, ok := rateLimitWait(resp.Header); ok {
+				delay = wait
+			}
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096)) // lets the connection be reused
+			resp.Body.Close()
+		}
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+		sleep := t.sleep
+		if sleep == nil {
+			sleep = sleepContext
+		}
+		if err := sleep(req, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry reports whether the request can safely be sent again. Rate limited requests were never processed,
+// so any of them can be retried. Other failures are only retried when repeating the request has no further effect.
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if !idempotent(req) {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	// exchanging client credentials for a token can be repeated freely
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, tokenPath)
+}
+
+// backoff returns a random delay of up to retryBaseDelay * 2^attempt, capped at retryMaxDelay
+func backoff(attempt int) time.Duration {
+	max := retryBaseDelay << uint(attempt)
+	if max <= 0 || max > retryMaxDelay {
+		max = retryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(max))) // #nosec G404 jitter doesn't need a secure source
+}
+
+// rateLimitWait reads how long the remote asked us to wait from the Retry-After header or, failing that,
+// the rate limit reset headers. OneLogin sends X-RateLimit-Reset as seconds from now and Okta sends
+// X-Rate-Limit-Reset as a unix timestamp, so large values are taken to be timestamps.
+func rateLimitWait(header http.Header) (time.Duration, bool) {
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if at, err := http.ParseTime(retryAfter); err == nil {
+			return nonNegative(time.Until(at)), true
+		}
+	}
+	for _, name := range []string{"X-RateLimit-Reset", "X-Rate-Limit-Reset"} {
+		reset, err := strconv.ParseInt(header.Get(name), 10, 64)
+		if err != nil || reset < 0 {
+			continue
+		}
+		if reset > 1000000000 {
+			return nonNegative(time.Until(time.Unix(reset, 0))), true
+		}
+		return time.Duration(reset) * time.Second, true
+	}
+	return 0, false
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func sleepContext(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
@@ -0,0 +1,294 @@
+package clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := map[string]struct {
+		Method, Path     string
+		Responses        []int // status returned for each attempt. The last repeats once they run out
+		RetryAfter       string
+		MaxRetries       int
+		ExpectedAttempts int
+		ExpectedStatus   int
+		ExpectedDelays   []time.Duration // only checked when the remote asks for a specific wait
+	}{
+		"It retries idempotent requests that fail transiently": {
+			Method:           http.MethodGet,
+			Path:             "/api/2/users",
+			Responses:        []int{503, 502, 200},
+			MaxRetries:       3,
+			ExpectedAttempts: 3,
+			ExpectedStatus:   200,
+		},
+		"It does not retry other requests that fail": {
+			Method:           http.MethodPost,
+			Path:             "/api/2/apps",
+			Responses:        []int{503},
+			MaxRetries:       3,
+			ExpectedAttempts: 1,
+			ExpectedStatus:   503,
+		},
+		"It retries token exchanges that fail": {
+			Method:           http.MethodPost,
+			Path:             tokenPath,
+			Responses:        []int{500, 200},
+			MaxRetries:       3,
+			ExpectedAttempts: 2,
+			ExpectedStatus:   200,
+		},
+		"It retries any rate limited request after the requested wait, resending its body": {
+			Method:           http.MethodPost,
+			Path:             "/api/2/apps",
+			Responses:        []int{429, 429, 201},
+			RetryAfter:       "2",
+			MaxRetries:       3,
+			ExpectedAttempts: 3,
+			ExpectedStatus:   201,
+			ExpectedDelays:   []time.Duration{2 * time.Second, 2 * time.Second},
+		},
+		"It caps the requested wait": {
+			Method:           http.MethodGet,
+			Path:             "/api/2/users",
+			Responses:        []int{429, 200},
+			RetryAfter:       "3600",
+			MaxRetries:       1,
+			ExpectedAttempts: 2,
+			ExpectedStatus:   200,
+			ExpectedDelays:   []time.Duration{retryMaxDelay},
+		},
+		"It gives up after the maximum number of retries": {
+			Method:           http.MethodGet,
+			Path:             "/api/2/users",
+			Responses:        []int{429},
+			MaxRetries:       2,
+			ExpectedAttempts: 3,
+			ExpectedStatus:   429,
+		},
+		"It does not retry when retries are disabled": {
+			Method:           http.MethodGet,
+			Path:             "/api/2/users",
+			Responses:        []int{503},
+			ExpectedAttempts: 1,
+			ExpectedStatus:   503,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				assert.Equal(t, `{"name":"test"}`, string(body), "every attempt should carry the full body")
+				status := test.Responses[len(test.Responses)-1]
+				if attempts < len(test.Responses) {
+					status = test.Responses[attempts]
+				}
+				attempts++
+				if test.RetryAfter != "" {
+					w.Header().Set("Retry-After", test.RetryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			delays := []time.Duration{}
+			client := &http.Client{Transport: &RetryTransport{
+				Next:       http.DefaultTransport,
+				MaxRetries: test.MaxRetries,
+				sleep: func(req *http.Request, d time.Duration) error {
+					delays = append(delays, d)
+					return nil
+				},
+			}}
+			req, _ := http.NewRequest(test.Method, server.URL+test.Path, strings.NewReader(`{"name":"test"}`))
+			resp, err := client.Do(req)
+			assert.Nil(t, err)
+			assert.Equal(t, test.ExpectedStatus, resp.StatusCode)
+			assert.Equal(t, test.ExpectedAttempts, attempts)
+			assert.Len(t, delays, test.ExpectedAttempts-1)
+			if test.ExpectedDelays != nil {
+				assert.Equal(t, test.ExpectedDelays, delays)
+			}
+		})
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	tests := map[string]struct {
+		Header     http.Header
+		Expected   time.Duration
+		ExpectedOK bool
+	}{
+		"It reads Retry-After seconds": {
+			Header:     http.Header{"Retry-After": []string{"7"}},
+			Expected:   7 * time.Second,
+			ExpectedOK: true,
+		},
+		"It reads a Retry-After date in the past as no wait": {
+			Header:     http.Header{"Retry-After": []string{"Wed, 21 Oct 2015 07:28:00 GMT"}},
+			Expected:   0,
+			ExpectedOK: true,
+		},
+		"It reads the OneLogin rate limit reset in seconds": {
+			Header:     http.Header{"X-Ratelimit-Reset": []string{"30"}},
+			Expected:   30 * time.Second,
+			ExpectedOK: true,
+		},
+		"It reads the Okta rate limit reset timestamp": {
+			Header:     http.Header{"X-Rate-Limit-Reset": []string{"1445412480"}},
+			Expected:   0,
+			ExpectedOK: true,
+		},
+		"It reports no wait without rate limit headers": {
+			Header: http.Header{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			wait, ok := rateLimitWait(test.Header)
+			assert.Equal(t, test.ExpectedOK, ok)
+			assert.Equal(t, test.Expected, wait)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		delay := backoff(attempt)
+		assert.True(t, delay >= 0 && delay < retryMaxDelay, "attempt %d waited %s", attempt, delay)
+		assert.True(t, delay < retryBaseDelay<<uint(attempt), "attempt %d waited %s", attempt, delay)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clientCert, clientKey := writeClientCertificate(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	caBundle := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	notPEM := filepath.Join(dir, "not.pem")
+	ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600)
+
+	tests := map[string]struct {
+		Configs          ClientConfigs
+		ExpectedError    bool
+		ExpectedRejected bool
+	}{
+		"It trusts the CA bundle and presents the client certificate": {
+			Configs: ClientConfigs{CABundle: caBundle, ClientCert: clientCert, ClientKey: clientKey},
+		},
+		"It does not trust the server without the CA bundle": {
+			Configs:          ClientConfigs{ClientCert: clientCert, ClientKey: clientKey},
+			ExpectedRejected: true,
+		},
+		"It is rejected by the server without the client certificate": {
+			Configs:          ClientConfigs{CABundle: caBundle},
+			ExpectedRejected: true,
+		},
+		"It rejects a CA bundle without certificates": {
+			Configs:       ClientConfigs{CABundle: notPEM},
+			ExpectedError: true,
+		},
+		"It rejects a missing CA bundle": {
+			Configs:       ClientConfigs{CABundle: filepath.Join(dir, "missing.pem")},
+			ExpectedError: true,
+		},
+		"It rejects a client certificate without its key": {
+			Configs:       ClientConfigs{ClientCert: clientCert},
+			ExpectedError: true,
+		},
+		"It rejects a relative proxy URL": {
+			Configs:       ClientConfigs{Proxy: "proxy:3128"},
+			ExpectedError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport, err := newTransport(test.Configs)
+			if test.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			defer transport.CloseIdleConnections()
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if test.ExpectedRejected {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		})
+	}
+}
+
+func TestNewTransportProxy(t *testing.T) {
+	proxied := []string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	transport, err := newTransport(ClientConfigs{Proxy: proxy.URL})
+	assert.Nil(t, err)
+	resp, err := (&http.Client{Transport: transport}).Get("http://api.onelogin.invalid/api/2/apps")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, []string{"http://api.onelogin.invalid/api/2/apps"}, proxied)
+}
+
+// writeClientCertificate writes a self signed client certificate and its key to dir and returns their paths
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "onelogin-cli-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certPath, keyPath
+}
@@ -0,0 +1,77 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/onelogin/onelogin/profiles"
+)
+
+const (
+	tokenPath     = "/auth/oauth2/v2/token" // OneLogin OAuth2 client credentials endpoint, relative to the API base URL
+	verifyTimeout = 10 * time.Second
+)
+
+// tokenResponse is the subset of the token endpoint's response used to verify credentials
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Scope       string `json:"scope"`
+	AccountID   int32  `json:"account_id"`
+}
+
+// VerifyProfile performs the OAuth client credentials token exchange with the profile's credentials against
+// its API endpoint and returns the scope the API granted. Credentials from a credential helper are fetched first.
+func VerifyProfile(profile profiles.Profile) (string, error) {
+	clientID, clientSecret := profile.ClientID, profile.ClientSecret
+	if profile.CredentialHelper != "" {
+		creds, err := runCredentialHelper(profile)
+		if err != nil {
+			return "", err
+		}
+		clientID, clientSecret = creds.ClientID, creds.ClientSecret
+	}
+	configs := ClientConfigs{}
+	configs.applySources(profile)
+	transport, err := newTransport(configs)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: verifyTimeout, Transport: transport}
+	return verifyCredentials(client, profile.BaseURL(), clientID, clientSecret)
+}
+
+func verifyCredentials(client *http.Client, baseURL, clientID, clientSecret string) (string, error) {
+	body, _ := json.Marshal(map[string]string{"grant_type": "client_credentials"})
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+tokenPath, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(clientID, clientSecret)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to reach %s: %s", baseURL, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token exchange failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	token := tokenResponse{}
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("unexpected response from token endpoint: %s", strings.TrimSpace(string(data)))
+	}
+	if token.Scope == "" {
+		return "unreported", nil
+	}
+	return token.Scope, nil
+}
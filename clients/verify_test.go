@@ -0,0 +1,71 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onelogin/onelogin/profiles"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTokenServer stands in for the OneLogin token endpoint, accepting only the id/secret pair test/test
+func newTokenServer(response string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if r.Method != http.MethodPost || r.URL.Path != tokenPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !ok || id != "test" || secret != "test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"statusCode":401,"name":"Unauthorized","message":"Authentication Failure"}`))
+			return
+		}
+		w.Write([]byte(response))
+	}))
+}
+
+func TestVerifyProfile(t *testing.T) {
+	tests := map[string]struct {
+		Response      string
+		Profile       profiles.Profile
+		ExpectedScope string
+		ExpectedError bool
+	}{
+		"It reports the granted scope": {
+			Response:      `{"access_token":"token","scope":"Read All","account_id":1}`,
+			Profile:       profiles.Profile{Name: "test", ClientID: "test", ClientSecret: "test"},
+			ExpectedScope: "Read All",
+		},
+		"It verifies credentials when no scope is reported": {
+			Response:      `{"access_token":"token","account_id":1}`,
+			Profile:       profiles.Profile{Name: "test", ClientID: "test", ClientSecret: "test"},
+			ExpectedScope: "unreported",
+		},
+		"It fails on rejected credentials": {
+			Response:      `{"access_token":"token"}`,
+			Profile:       profiles.Profile{Name: "test", ClientID: "test", ClientSecret: "wrong"},
+			ExpectedError: true,
+		},
+		"It fails when no token is issued": {
+			Response:      `{}`,
+			Profile:       profiles.Profile{Name: "test", ClientID: "test", ClientSecret: "test"},
+			ExpectedError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newTokenServer(test.Response)
+			defer server.Close()
+			test.Profile.APIURL = server.URL
+			scope, err := VerifyProfile(test.Profile)
+			if test.ExpectedError {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, test.ExpectedScope, scope)
+			}
+		})
+	}
+}
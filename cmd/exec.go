@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/onelogin/onelogin/clients"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	execCommand := &cobra.Command{
+		Use:   "exec [--profile name] -- command [args...]",
+		Short: "Runs a command with a profile's credentials in its environment",
+		Long: `Resolves credentials the same way every other command does (--profile, then ONELOGIN_PROFILE, then the active profile,
+		then the environment) and runs the given command with them exported as ONELOGIN_CLIENT_ID, ONELOGIN_CLIENT_SECRET and
+		ONELOGIN_OAPI_URL, along with any OKTA_ORG_NAME, OKTA_BASE_URL, OKTA_API_TOKEN, AWS_REGION and AWS_PROFILE settings.
+		e.g. onelogin exec --profile prod -- terraform plan`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			// #nosec G204 running the user's command is the point
+			child := exec.Command(args[0], args[1:]...)
+			child.Env = append(os.Environ(), resolveClientConfigs().Environ()...)
+			child.Stdin = os.Stdin
+			child.Stdout = os.Stdout
+			child.Stderr = os.Stderr
+			if err := child.Run(); err != nil {
+				if exitErr, ok := err.(*exec.ExitError); ok {
+					os.Exit(exitErr.ExitCode())
+				}
+				log.Fatalln("Unable to run", args[0], err)
+			}
+		},
+	}
+	execCommand.Flags().SetInterspersed(false) // flags after the command name belong to the command
+
+	envCommand := &cobra.Command{
+		Use:   "env",
+		Short: "Prints a profile's credentials as shell export statements",
+		Long: `Prints the variables onelogin exec would set as export statements for POSIX shells.
+		e.g. eval "$(onelogin env --profile prod)"`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, v := range resolveClientConfigs().Environ() {
+				kv := strings.SplitN(v, "=", 2)
+				fmt.Printf("export %s=%s\n", kv[0], shellQuote(kv[1]))
+			}
+		},
+	}
+	rootCmd.AddCommand(execCommand)
+	rootCmd.AddCommand(envCommand)
+}
+
+// resolveClientConfigs resolves the credentials for this invocation without building any clients
+func resolveClientConfigs() clients.ClientConfigs {
+	configFile, err := os.OpenFile(viper.ConfigFileUsed(), os.O_RDWR, 0600)
+	if err != nil {
+		configFile.Close()
+		log.Println("Unable to open profiles file. Falling back to Environment Variables", err)
+	}
+	defer configFile.Close()
+	return clients.New(configFile, selectedProfile()).ClientConfigs
+}
+
+// shellQuote single quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
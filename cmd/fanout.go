@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/onelogin/onelogin/clients"
+	"github.com/onelogin/onelogin/profiles"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// fanOutInput collects the flags that run a read-only command against several profiles at once
+type fanOutInput struct {
+	profiles    []string
+	allProfiles bool
+}
+
+func (in *fanOutInput) register(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&in.profiles, "profiles", nil, "Comma separated profiles to run against concurrently, e.g. --profiles a,b,c")
+	flags.BoolVar(&in.allProfiles, "all-profiles", false, "Run against every profile concurrently")
+}
+
+// enabled reports whether the command should fan out rather than use a single profile
+func (in *fanOutInput) enabled() bool {
+	return in.allProfiles || len(in.profiles) > 0
+}
+
+// fanOutTarget is a selected profile's client list, or the error that kept it from being set up
+type fanOutTarget struct {
+	clientList *clients.Clients
+	err        error
+}
+
+// resolve returns the client list for each selected profile, keyed by profile name. A profile that can't be
+// set up is kept with its error so it is reported as failed rather than stopping the others.
+// Credential helpers run one profile at a time here so any prompts they show don't interleave.
+func (in *fanOutInput) resolve() map[string]fanOutTarget {
+	if in.allProfiles && len(in.profiles) > 0 {
+		log.Fatalln("--profiles and --all-profiles cannot be used together")
+	}
+	if profileName != "" {
+		log.Fatalln("--profile cannot be used with --profiles or --all-profiles")
+	}
+	configFile, err := os.OpenFile(viper.ConfigFileUsed(), os.O_RDWR, 0600)
+	if err != nil {
+		log.Fatalln("Unable to open profiles file", err)
+	}
+	defer configFile.Close()
+	profileService := profiles.ProfileService{Repository: profiles.OpenRepository(configFile)}
+
+	names := in.profiles
+	if in.allProfiles {
+		for name := range profileService.Index() {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			log.Fatalln("No profiles set up!")
+		}
+	}
+	targets := map[string]fanOutTarget{}
+	for _, name := range names {
+		clientList, err := clients.LoadProfile(profileService.Select(name))
+		if err != nil {
+			targets[name] = fanOutTarget{err: err}
+			continue
+		}
+		if profileService.Encrypted() {
+			clientList.Tokens = nil // the token cache is plaintext, so it isn't used alongside encrypted profiles
+		}
+		clientList = applyHTTPFlags(clientList)
+		if clientList.Debug != nil {
+			clientList.Debug = log.New(clientList.Debug.Writer(), fmt.Sprintf("[DEBUG] [%s] ", name), clientList.Debug.Flags())
+		}
+		targets[name] = fanOutTarget{clientList: clientList}
+	}
+	return targets
+}
+
+// fanOut calls run for every profile that was set up concurrently, then reports whether each profile succeeded
+// and exits non-zero if any failed
+func fanOut(targets map[string]fanOutTarget, run func(name string, clientList *clients.Clients) error) {
+	if runFanOut(targets, run, os.Stderr) > 0 {
+		os.Exit(1)
+	}
+}
+
+// runFanOut is fanOut without the exit. It reports each profile's outcome to report and returns how many failed.
+func runFanOut(targets map[string]fanOutTarget, run func(name string, clientList *clients.Clients) error, report io.Writer) int {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := make([]error, len(names))
+	wg := sync.WaitGroup{}
+	for i, name := range names {
+		if targets[name].err != nil {
+			errs[i] = targets[name].err
+			continue
+		}
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			// a panic in one profile's run is its failure, not every profile's
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			errs[i] = run(name, targets[name].clientList)
+		}(i, name)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			fmt.Fprintf(report, "[%s] FAILED: %s\n", names[i], err)
+		} else {
+			fmt.Fprintf(report, "[%s] OK\n", names[i])
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(report, "%d of %d profiles failed\n", failed, len(names))
+	}
+	return failed
+}
+
+// outputMutex serializes lines written by prefixWriters sharing an output
+var outputMutex sync.Mutex
+
+// prefixWriter prefixes every line written to it, so the output of profiles running concurrently can be told apart.
+// Only whole lines are written through. Call Flush once done to write out any trailing partial line.
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func newPrefixWriter(out io.Writer, profile string) *prefixWriter {
+	return &prefixWriter{out: out, prefix: fmt.Sprintf("[%s] ", profile)}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+func (w *prefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeLine(append(w.buf, '\n'))
+	w.buf = nil
+	return err
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	_, err := w.out.Write(append([]byte(w.prefix), line...))
+	return err
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/onelogin/onelogin/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFanOut(t *testing.T) {
+	tests := map[string]struct {
+		Targets        map[string]fanOutTarget
+		Errors         map[string]error
+		Panics         map[string]bool
+		ExpectedFailed int
+		ExpectedReport string
+		ExpectedRun    []string
+	}{
+		"It reports every profile that succeeded": {
+			Targets:        map[string]fanOutTarget{"b": {clientList: &clients.Clients{}}, "a": {clientList: &clients.Clients{}}},
+			ExpectedReport: "[a] OK\n[b] OK\n",
+			ExpectedRun:    []string{"a", "b"},
+		},
+		"It reports the errors of those that failed and how many did": {
+			Targets:        map[string]fanOutTarget{"a": {clientList: &clients.Clients{}}, "b": {clientList: &clients.Clients{}}, "c": {clientList: &clients.Clients{}}},
+			Errors:         map[string]error{"a": errors.New("unauthorized"), "c": errors.New("timed out")},
+			ExpectedFailed: 2,
+			ExpectedReport: "[a] FAILED: unauthorized\n[b] OK\n[c] FAILED: timed out\n2 of 3 profiles failed\n",
+			ExpectedRun:    []string{"a", "b", "c"},
+		},
+		"It reports profiles that couldn't be set up without running them": {
+			Targets:        map[string]fanOutTarget{"a": {err: errors.New("credential helper failed")}, "b": {clientList: &clients.Clients{}}},
+			ExpectedFailed: 1,
+			ExpectedReport: "[a] FAILED: credential helper failed\n[b] OK\n1 of 2 profiles failed\n",
+			ExpectedRun:    []string{"b"},
+		},
+		"It reports a panic as that profile's failure": {
+			Targets:        map[string]fanOutTarget{"a": {clientList: &clients.Clients{}}, "b": {clientList: &clients.Clients{}}},
+			Panics:         map[string]bool{"a": true},
+			ExpectedFailed: 1,
+			ExpectedReport: "[a] FAILED: panic: boom\n[b] OK\n1 of 2 profiles failed\n",
+			ExpectedRun:    []string{"a", "b"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ran := make(chan string, len(test.Targets))
+			report := bytes.Buffer{}
+			failed := runFanOut(test.Targets, func(name string, clientList *clients.Clients) error {
+				ran <- name
+				if test.Panics[name] {
+					panic("boom")
+				}
+				return test.Errors[name]
+			}, &report)
+			close(ran)
+			assert.Equal(t, test.ExpectedFailed, failed)
+			assert.Equal(t, test.ExpectedReport, report.String())
+			actualRun := []string{}
+			for name := range ran {
+				actualRun = append(actualRun, name)
+			}
+			assert.ElementsMatch(t, test.ExpectedRun, actualRun)
+		})
+	}
+}
+
+// TestFanOutExit runs fanOut in a child process, as it exits the process when a profile fails
+func TestFanOutExit(t *testing.T) {
+	if os.Getenv("FANOUT_EXIT_CHILD") != "" {
+		fanOut(map[string]fanOutTarget{"a": {clientList: &clients.Clients{}}}, func(name string, clientList *clients.Clients) error {
+			if os.Getenv("FANOUT_EXIT_CHILD") == "fail" {
+				return errors.New("unauthorized")
+			}
+			return nil
+		})
+		return
+	}
+	tests := map[string]struct {
+		Child        string
+		ExpectedCode int
+	}{
+		"It exits zero when every profile succeeds": {Child: "succeed"},
+		"It exits non-zero when a profile fails":    {Child: "fail", ExpectedCode: 1},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// #nosec G204 re-running this test binary
+			cmd := exec.Command(os.Args[0], "-test.run=^TestFanOutExit$")
+			cmd.Env = append(os.Environ(), "FANOUT_EXIT_CHILD="+test.Child)
+			err := cmd.Run()
+			code := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				code = exitErr.ExitCode()
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, test.ExpectedCode, code)
+		})
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	tests := map[string]struct {
+		Writes   []string
+		Flush    bool
+		Expected string
+	}{
+		"It prefixes every line": {
+			Writes:   []string{"one\ntwo\n"},
+			Expected: "[prod] one\n[prod] two\n",
+		},
+		"It joins lines written in pieces": {
+			Writes:   []string{"o", "ne\nt", "wo\n"},
+			Expected: "[prod] one\n[prod] two\n",
+		},
+		"It holds a partial line back until flushed": {
+			Writes:   []string{"one\ntw"},
+			Expected: "[prod] one\n",
+		},
+		"It writes out a trailing partial line on flush": {
+			Writes:   []string{"one\ntwo"},
+			Flush:    true,
+			Expected: "[prod] one\n[prod] two\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			w := newPrefixWriter(&out, "prod")
+			for _, s := range test.Writes {
+				n, err := w.Write([]byte(s))
+				assert.Nil(t, err)
+				assert.Equal(t, len(s), n)
+			}
+			if test.Flush {
+				assert.Nil(t, w.Flush())
+			}
+			assert.Equal(t, test.Expected, out.String())
+		})
+	}
+}
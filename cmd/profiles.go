@@ -1,17 +1,28 @@
 package cmd
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sort"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/onelogin/onelogin/clients"
 	"github.com/onelogin/onelogin/profiles"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+var (
+	revealSecrets  bool
+	profilesOutput string
+)
+
 func init() {
 	legalActions := map[string]interface{}{
 		"add":     add,
@@ -26,8 +37,12 @@ func init() {
 		"delete":  remove,
 		"which":   current,
 		"current": current,
+		"encrypt": encrypt,
+		"decrypt": decrypt,
+		"logout":  logout,
 	}
-	rootCmd.AddCommand(&cobra.Command{
+	input := profileInput{}
+	initCommand := &cobra.Command{
 		Use:   "init",
 		Short: "Creates a default OneLogin Profile",
 		Long:  "Creates and activates the first OneLogin Profile",
@@ -38,7 +53,7 @@ func init() {
 				log.Fatalln("Unable to open profiles file", err)
 			}
 			profileService := profiles.ProfileService{
-				Repository:  profiles.FileRepository{StorageMedia: configFile},
+				Repository:  profiles.OpenRepository(configFile),
 				InputReader: os.Stdin,
 			}
 			if len(profileService.Index()) > 0 {
@@ -55,14 +70,17 @@ func init() {
 				log.Fatalln("Unable to open profiles file", err)
 			}
 			profileService := profiles.ProfileService{
-				Repository:  profiles.FileRepository{StorageMedia: configFile},
-				InputReader: os.Stdin,
+				Repository:     profiles.OpenRepository(configFile),
+				InputReader:    os.Stdin,
+				Preset:         &input.preset,
+				NonInteractive: input.read(cmd),
+				Verifier:       input.verifier(),
 			}
 			profileService.Create("default")
 			configFile.Close()
 		},
-	})
-	rootCmd.AddCommand(&cobra.Command{
+	}
+	profilesCommand := &cobra.Command{
 		Use:   "profiles",
 		Short: "Manage account settings for the CLI",
 		Long: `Maintains a listing of accounts used by the CLI in a home/.onelogin/profiles file
@@ -75,7 +93,29 @@ func init() {
 			remove (delete) [name - required] => removes selected profile
 			add    (create) [name - required] => adds profile to manage
 			list   (ls)     [name - optional] => lists managed profile that can be used. if name given, lists information about that profile
-			which  (current)                  => returns current active profile`,
+			                                     list and show mask client secrets and API tokens unless --reveal is given.
+			                                     --output table prints a table instead of JSON
+			which  (current)                  => returns current active profile
+			encrypt                           => encrypts the profiles file with a passphrase. Set ONELOGIN_PROFILES_PASSPHRASE to skip the prompt
+			decrypt                           => converts an encrypted profiles file back to plaintext
+			logout          [name - optional] => forgets the cached access token for the profile, or every cached token if no name given
+		Flags for add and edit (giving any of these skips the interactive prompts and fails on missing or invalid values):
+			--region [us|eu]                  => the profile's region
+			--client-id [id]                  => the profile's client id
+			--api-url [url]                   => explicit API base URL (e.g. a shard, staging stack or local mock server). Region becomes optional
+			--subdomain [subdomain]           => tenant subdomain, the API is addressed as https://<subdomain>.onelogin.com. Region becomes optional
+			--client-secret-stdin             => read the profile's client secret from the first line of stdin
+			--json                            => read the profile from stdin as JSON e.g. {"region": "us", "client_id": "...", "client_secret": "..."}. Flags take precedence
+			--okta-org-name [name]            => Okta org used by terraform-import okta_apps, overrides OKTA_ORG_NAME
+			--okta-base-url [url]             => Okta base URL e.g. okta.com, overrides OKTA_BASE_URL. Give the API token in the --json document as {"okta": {"api_token": "..."}}
+			--aws-region [region]             => AWS region used by terraform-import aws_iam_user, overrides AWS_REGION
+			--aws-profile [name]              => AWS shared config profile used by terraform-import aws_iam_user
+			--verify[=strict|warn]            => exchange the credentials for an access token before saving and report the granted scope.
+			                                     strict refuses to save the profile if that fails, warn saves it anyway
+			--credential-helper [helper]      => fetch the client id and secret from this executable at runtime instead of storing them.
+			                                     The helper is run as "<helper> get" (bare names are looked up as onelogin-credential-<helper>),
+			                                     receives {"profile": name, "region": region, "client_id": id} on stdin and must print
+			                                     {"client_id": id, "client_secret": secret} to stdout`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 0 {
 				log.Fatalf("Must specify action to perform!")
@@ -100,20 +140,125 @@ func init() {
 				log.Fatalln("Unable to open profiles file", err)
 			}
 			profileService := profiles.ProfileService{
-				Repository:  profiles.FileRepository{StorageMedia: configFile},
+				Repository:  profiles.OpenRepository(configFile),
 				InputReader: os.Stdin,
+				Preset:      &input.preset,
+			}
+			switch action {
+			case "add", "create", "edit", "update":
+				profileService.NonInteractive = input.read(cmd)
+				profileService.Verifier = input.verifier()
+			case "list", "ls", "show":
+				if profilesOutput != "json" && profilesOutput != "table" {
+					log.Fatalln("--output must be json or table")
+				}
 			}
 			if f, ok := legalActions[action].(func(s string, pr profiles.ProfileService)); ok {
 				profileName := args[1]
 				f(profileName, profileService)
 			} else if f, ok := legalActions[action].(func(pr profiles.ProfileService)); ok {
 				f(profileService)
+			} else if f, ok := legalActions[action].(func(names []string, pr profiles.ProfileService)); ok {
+				f(args[1:], profileService)
 			} else {
 				log.Fatalf("Unexpected Error!")
 			}
 			configFile.Close()
 		},
+	}
+	input.register(initCommand.Flags())
+	input.register(profilesCommand.Flags())
+	profilesCommand.Flags().BoolVar(&revealSecrets, "reveal", false, "Show client secrets and API tokens in list and show output instead of masking them")
+	profilesCommand.Flags().StringVar(&profilesOutput, "output", "json", "Output format for list and show (json or table)")
+	rootCmd.AddCommand(initCommand)
+	rootCmd.AddCommand(profilesCommand)
+}
+
+// profileInput collects the profile values given as flags to init, profiles add and profiles edit
+type profileInput struct {
+	preset                         profiles.Profile
+	okta                           profiles.OktaSource
+	aws                            profiles.AWSSource
+	secretFromStdin, jsonFromStdin bool
+	verify                         string
+}
+
+func (in *profileInput) register(flags *pflag.FlagSet) {
+	flags.StringVar(&in.preset.Region, "region", "", "Profile region (us or eu)")
+	flags.StringVar(&in.preset.ClientID, "client-id", "", "Profile client id")
+	flags.StringVar(&in.preset.APIURL, "api-url", "", "Explicit API base URL, e.g. for a shard, staging stack or local mock server")
+	flags.StringVar(&in.preset.Subdomain, "subdomain", "", "Tenant subdomain. The API is addressed as https://<subdomain>.onelogin.com unless --api-url is given")
+	flags.BoolVar(&in.secretFromStdin, "client-secret-stdin", false, "Read the profile client secret from the first line of stdin")
+	flags.BoolVar(&in.jsonFromStdin, "json", false, `Read the profile from stdin as JSON e.g. {"region": "us", "client_id": "...", "client_secret": "..."}`)
+	flags.StringVar(&in.preset.CredentialHelper, "credential-helper", "", "Executable that supplies the client id and secret at runtime instead of storing them in the profile")
+	flags.StringVar(&in.okta.OrgName, "okta-org-name", "", "Okta org name used as an import source")
+	flags.StringVar(&in.okta.BaseURL, "okta-base-url", "", "Okta base URL used as an import source e.g. okta.com")
+	flags.StringVar(&in.aws.Region, "aws-region", "", "AWS region used as an import source")
+	flags.StringVar(&in.aws.Profile, "aws-profile", "", "AWS shared config profile used as an import source")
+	flags.StringVar(&in.verify, "verify", "", "Exchange the credentials for an access token before saving. strict (default) refuses to save on failure, warn only reports it")
+	flags.Lookup("verify").NoOptDefVal = "strict"
+}
+
+// verifier returns the credential check requested with --verify, or nil if none was
+func (in *profileInput) verifier() func(profiles.Profile) error {
+	switch in.verify {
+	case "":
+		return nil
+	case "strict", "warn":
+	default:
+		log.Fatalln("--verify must be strict or warn")
+	}
+	return func(p profiles.Profile) error {
+		fmt.Println("Verifying credentials against", p.BaseURL())
+		scope, err := clients.VerifyProfile(p)
+		if err != nil && in.verify == "warn" {
+			fmt.Println("WARNING: unable to verify credentials:", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("Credentials verified. Granted scope:", scope)
+		return nil
+	}
+}
+
+// read completes the preset from stdin when --json or --client-secret-stdin is given and
+// reports whether any profile flag was used, in which case the interactive prompts are skipped
+func (in *profileInput) read(cmd *cobra.Command) bool {
+	if in.secretFromStdin && in.jsonFromStdin {
+		log.Fatalln("--json and --client-secret-stdin cannot be used together")
+	}
+	if in.okta != (profiles.OktaSource{}) {
+		in.preset.Okta = &in.okta
+	}
+	if in.aws != (profiles.AWSSource{}) {
+		in.preset.AWS = &in.aws
+	}
+	if in.jsonFromStdin {
+		fromJSON := profiles.Profile{}
+		if err := json.NewDecoder(os.Stdin).Decode(&fromJSON); err != nil {
+			log.Fatalln("Unable to parse profile JSON from stdin", err)
+		}
+		fromJSON.Apply(&in.preset) // flags take precedence over the JSON document
+		in.preset = fromJSON
+	}
+	if in.secretFromStdin {
+		secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			log.Fatalln("Unable to read client secret from stdin", err)
+		}
+		in.preset.ClientSecret = strings.TrimRight(secret, "\r\n")
+	}
+	nonInteractive := in.secretFromStdin || in.jsonFromStdin
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		switch f.Name {
+		case "region", "client-id", "api-url", "subdomain", "credential-helper",
+			"okta-org-name", "okta-base-url", "aws-region", "aws-profile":
+			nonInteractive = true
+		}
 	})
+	return nonInteractive
 }
 
 func add(name string, pr profiles.ProfileService) {
@@ -123,11 +268,27 @@ func add(name string, pr profiles.ProfileService) {
 
 func list(pr profiles.ProfileService) {
 	out := pr.Index()
-	profiles := make([]profiles.Profile, len(out))
-	i := 0
-	for _, p := range out {
-		profiles[i] = *p
-		i++
+	names := make([]string, 0, len(out))
+	for name := range out {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	profiles := make([]profiles.Profile, len(names))
+	for i, name := range names {
+		profiles[i] = displayProfile(*out[name])
+	}
+	if profilesOutput == "table" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tACTIVE\tAPI URL\tCLIENT ID\tCLIENT SECRET\tCREDENTIAL HELPER")
+		for _, p := range profiles {
+			active := ""
+			if p.Active {
+				active = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Name, active, p.BaseURL(), p.ClientID, p.ClientSecret, p.CredentialHelper)
+		}
+		w.Flush()
+		return
 	}
 	printout, _ := json.MarshalIndent(profiles, "", " ")
 	fmt.Println(string(printout))
@@ -135,13 +296,53 @@ func list(pr profiles.ProfileService) {
 
 func show(name string, pr profiles.ProfileService) {
 	out := pr.Find(name)
-	if out != nil {
-		printout, _ := json.MarshalIndent(out, "", " ")
-		fmt.Println(string(printout))
+	if out == nil {
+		return
+	}
+	p := displayProfile(*out)
+	if profilesOutput == "table" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		rows := [][2]string{
+			{"Name", p.Name},
+			{"Active", fmt.Sprint(p.Active)},
+			{"Region", p.Region},
+			{"API URL", p.BaseURL()},
+			{"Client ID", p.ClientID},
+			{"Client Secret", p.ClientSecret},
+			{"Credential Helper", p.CredentialHelper},
+		}
+		if p.Okta != nil {
+			rows = append(rows, [2]string{"Okta Org Name", p.Okta.OrgName}, [2]string{"Okta Base URL", p.Okta.BaseURL}, [2]string{"Okta API Token", p.Okta.APIToken})
+		}
+		if p.AWS != nil {
+			rows = append(rows, [2]string{"AWS Region", p.AWS.Region}, [2]string{"AWS Profile", p.AWS.Profile})
+		}
+		for _, row := range rows {
+			if row[1] != "" {
+				fmt.Fprintf(w, "%s:\t%s\n", row[0], row[1])
+			}
+		}
+		w.Flush()
+		return
+	}
+	printout, _ := json.MarshalIndent(p, "", " ")
+	fmt.Println(string(printout))
+}
+
+// displayProfile masks the profile's secrets unless --reveal was given
+func displayProfile(p profiles.Profile) profiles.Profile {
+	if revealSecrets {
+		return p
 	}
+	return p.Redacted()
 }
 
 func current(pr profiles.ProfileService) {
+	if name := selectedProfile(); name != "" {
+		pr.Select(name)
+		fmt.Println("Current Profile:", name, "(selected with --profile or", ProfileEnvVar+")")
+		return
+	}
 	profiles := pr.Index()
 	var active string
 	for name, p := range profiles {
@@ -167,3 +368,42 @@ func remove(name string, pr profiles.ProfileService) {
 	pr.Remove(name)
 	fmt.Println("Successfully removed:", name)
 }
+
+func logout(names []string, pr profiles.ProfileService) {
+	for _, name := range names {
+		pr.Select(name) // fails on unknown profiles rather than silently doing nothing
+	}
+	if err := clients.DefaultTokenCache().Forget(names...); err != nil {
+		log.Fatalln("Unable to clear cached access tokens", err)
+	}
+	if len(names) == 0 {
+		fmt.Println("Cleared all cached access tokens")
+	} else {
+		fmt.Println("Cleared cached access tokens for:", strings.Join(names, ", "))
+	}
+}
+
+func encrypt(pr profiles.ProfileService) {
+	repository, ok := pr.Repository.(profiles.FileRepository)
+	if !ok {
+		log.Fatalln("Profiles file is already encrypted!")
+	}
+	pr.MigrateTo(profiles.EncryptedFileRepository{
+		StorageMedia: repository.StorageMedia,
+		Passphrase:   profiles.ReadPassphrase(true),
+	})
+	// tokens aren't cached once profiles are encrypted, so don't leave the plaintext ones behind
+	if err := clients.DefaultTokenCache().Forget(); err != nil {
+		log.Println("Unable to remove cached access tokens", err)
+	}
+	fmt.Println("Successfully encrypted profiles")
+}
+
+func decrypt(pr profiles.ProfileService) {
+	repository, ok := pr.Repository.(profiles.EncryptedFileRepository)
+	if !ok {
+		log.Fatalln("Profiles file is not encrypted!")
+	}
+	pr.MigrateTo(profiles.FileRepository{StorageMedia: repository.StorageMedia})
+	fmt.Println("Successfully decrypted profiles")
+}
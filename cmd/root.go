@@ -20,14 +20,35 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/onelogin/onelogin/clients"
 	"github.com/spf13/cobra"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var cfgFile, profileName string
+
+// timeout and retry overrides, applied over the profile and environment settings by applyHTTPFlags
+var timeoutSeconds, maxRetries int
+
+// cassette directories for recording or replaying HTTP traffic, applied by applyHTTPFlags
+var recordDir, replayDir string
+
+// proxy and TLS overrides, applied over the profile settings by applyHTTPFlags
+var proxyURL, caBundle, clientCert, clientKey string
+
+// HTTP tracing, applied by applyHTTPFlags. The logger is shared by every client list in the invocation
+var (
+	debug       bool
+	debugFile   string
+	debugLogger *log.Logger
+)
+
+// ProfileEnvVar names the environment variable that selects a profile when --profile is not given
+const ProfileEnvVar = "ONELOGIN_PROFILE"
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -54,6 +75,17 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.onelogin.json)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", fmt.Sprintf("profile to use for this command instead of the active profile (or set %s)", ProfileEnvVar))
+	rootCmd.PersistentFlags().IntVar(&timeoutSeconds, "timeout", 0, fmt.Sprintf("seconds to wait for each response from OneLogin, Okta or AWS (or set %s, default %d)", clients.TimeoutEnvVar, int(clients.DefaultTimeout.Seconds())))
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", 0, fmt.Sprintf("times to retry rate limited or failed requests, 0 disables retries (or set %s, default %d)", clients.MaxRetriesEnvVar, clients.DefaultMaxRetries))
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "record scrubbed OneLogin and Okta requests and responses to a cassette in this directory")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "answer OneLogin and Okta requests from the cassette recorded in this directory instead of the network")
+	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", "", "proxy URL for OneLogin, Okta and AWS requests (default from HTTPS_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&caBundle, "ca-bundle", "", "PEM file of certificates to trust in addition to the system roots")
+	rootCmd.PersistentFlags().StringVar(&clientCert, "client-cert", "", "PEM client certificate to present for mutual TLS. Requires --client-key")
+	rootCmd.PersistentFlags().StringVar(&clientKey, "client-key", "", "PEM private key for --client-cert")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "log every request to OneLogin, Okta and AWS to stderr with secrets redacted")
+	rootCmd.PersistentFlags().StringVar(&debugFile, "debug-file", "", "write --debug output to this file instead of stderr. Implies --debug")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -70,6 +102,65 @@ func init() {
 	})
 }
 
+// selectedProfile returns the profile chosen for this invocation with --profile or ONELOGIN_PROFILE.
+// A blank name means the active profile should be used.
+func selectedProfile() string {
+	if profileName != "" {
+		return profileName
+	}
+	return os.Getenv(ProfileEnvVar)
+}
+
+// applyHTTPFlags overrides the timeout and retry settings resolved from the profile and environment with
+// any given as flags, along with the proxy and TLS settings, and turns on recording, replaying or tracing
+func applyHTTPFlags(clientList *clients.Clients) *clients.Clients {
+	flags := rootCmd.PersistentFlags()
+	if debug || debugFile != "" {
+		if debugLogger == nil {
+			out := os.Stderr
+			if debugFile != "" {
+				f, err := os.OpenFile(debugFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+				if err != nil {
+					log.Fatalln("Unable to open debug file", err)
+				}
+				out = f // left open for the life of the process
+			}
+			debugLogger = log.New(out, "[DEBUG] ", log.LstdFlags|log.Lmicroseconds)
+		}
+		clientList.Debug = debugLogger
+	}
+	if recordDir != "" && replayDir != "" {
+		log.Fatalln("--record and --replay cannot be used together")
+	}
+	clientList.RecordDir = recordDir
+	clientList.ReplayDir = replayDir
+	if flags.Changed("timeout") {
+		if timeoutSeconds < 0 {
+			log.Fatalln("--timeout cannot be negative")
+		}
+		clientList.Timeout = time.Duration(timeoutSeconds) * time.Second
+	}
+	if flags.Changed("max-retries") {
+		if maxRetries < 0 {
+			log.Fatalln("--max-retries cannot be negative")
+		}
+		clientList.MaxRetries = maxRetries
+	}
+	if proxyURL != "" {
+		clientList.Proxy = proxyURL
+	}
+	if caBundle != "" {
+		clientList.CABundle = caBundle
+	}
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			log.Fatalln("--client-cert and --client-key must be used together")
+		}
+		clientList.ClientCert, clientList.ClientKey = clientCert, clientKey
+	}
+	return clientList
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	home, err := homedir.Dir()
@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -28,6 +29,7 @@ func init() {
 		smarthookName, smarthookType *string
 		verbose                      *bool
 		oneloginClient               *client.APIClient
+		fanOutFlags                  fanOutInput
 	)
 
 	smarthooksCommand := &cobra.Command{
@@ -45,17 +47,41 @@ func init() {
 
 			env_vars                                   => lists the defined environment variable names. E.g. environment variables like FOO=bar BING=baz would turn up [FOO, BING].
 			put_env_vars [key=value pairs - required]  => creates or updates the environment variable with the given key. Must be given as FOO=bar BING=baz.
-			rm_env_vars  [key - required]              => deletes the environment variable with the given key.`,
+			rm_env_vars  [key - required]              => deletes the environment variable with the given key.
+
+		list and env_vars accept --profiles a,b,c or --all-profiles to query several accounts concurrently.
+		Each line of output is prefixed with the profile it came from and the command fails if any profile does.`,
 		PreRun: func(cmd *cobra.Command, args []string) {
 			action = strings.ToLower(args[0])
+			if fanOutFlags.enabled() {
+				if action != "list" && action != "env_vars" {
+					log.Fatalln("--profiles and --all-profiles are only supported for list and env_vars")
+				}
+				return
+			}
 			credsFile, err := os.OpenFile(viper.ConfigFileUsed(), os.O_RDWR, 0600)
 			if err != nil {
 				credsFile.Close()
 				log.Println("Unable to open profiles file. Falling back to Environment Variables", err)
 			}
-			oneloginClient = clients.New(credsFile).OneLoginClient()
+			oneloginClient = applyHTTPFlags(clients.New(credsFile, selectedProfile())).OneLoginClient()
 		},
 		Run: func(cmd *cobra.Command, args []string) {
+			if fanOutFlags.enabled() {
+				fanOut(fanOutFlags.resolve(), func(name string, clientList *clients.Clients) error {
+					oneloginClient, err := clientList.LoadOneLoginClient()
+					if err != nil {
+						return err
+					}
+					out := newPrefixWriter(os.Stdout, name)
+					defer out.Flush()
+					if action == "list" {
+						return listHooks(*smarthookType, *verbose, oneloginClient, out)
+					}
+					return listEnvs(oneloginClient, out)
+				})
+				return
+			}
 			switch action {
 			case "new":
 				if len(args) < 2 {
@@ -64,7 +90,9 @@ func init() {
 					newHook(args[1])
 				}
 			case "list":
-				listHooks(*smarthookType, *verbose, oneloginClient)
+				if err := listHooks(*smarthookType, *verbose, oneloginClient, os.Stdout); err != nil {
+					log.Fatalln("Unable to query Smart Hooks", err)
+				}
 			case "get":
 				if len(args) < 2 {
 					log.Fatalf("One argument is required for this action!")
@@ -77,7 +105,9 @@ func init() {
 			case "delete":
 				deleteHook(args[1:], oneloginClient)
 			case "env_vars":
-				listEnvs(oneloginClient)
+				if err := listEnvs(oneloginClient, os.Stdout); err != nil {
+					log.Fatalln("Unable to query Smart Hook Environment Variables", err)
+				}
 			case "put_env_vars":
 				putEnvs(args[1:], oneloginClient)
 			case "rm_env_vars":
@@ -91,6 +121,7 @@ func init() {
 	smarthookName = smarthooksCommand.Flags().StringP("name", "n", "unnamed", "Smart Hook name")
 	smarthookType = smarthooksCommand.Flags().StringP("type", "t", "", "Smart Hook type")
 	verbose = smarthooksCommand.Flags().BoolP("verbose", "v", false, "verbose output")
+	fanOutFlags.register(smarthooksCommand.Flags())
 	rootCmd.AddCommand(smarthooksCommand)
 }
 
@@ -205,18 +236,19 @@ func newHook(name string) {
 	fmt.Println("To deploy your Smart Hook run 'onelogin smarthooks deploy' from the project directory")
 }
 
-func listHooks(hookType string, verbose bool, client *client.APIClient) {
+func listHooks(hookType string, verbose bool, client *client.APIClient, out io.Writer) error {
 	hooks, err := client.Services.SmartHooksV1.Query(&smarthooks.SmartHookQuery{Type: hookType})
 	if err != nil {
-		log.Fatalln("Unable to query Smart Hooks", err)
+		return err
 	}
 	for _, h := range hooks {
 		if verbose {
-			fmt.Println(*h.Type, *h.ID)
+			fmt.Fprintln(out, *h.Type, *h.ID)
 		} else {
-			fmt.Println(*h.ID)
+			fmt.Fprintln(out, *h.ID)
 		}
 	}
+	return nil
 }
 
 func getHook(id string, client *client.APIClient) {
@@ -348,14 +380,15 @@ func deleteHook(ids []string, client *client.APIClient) {
 	log.Println("Finished deleting hooks")
 }
 
-func listEnvs(client *client.APIClient) {
+func listEnvs(client *client.APIClient, out io.Writer) error {
 	vars, err := client.Services.SmartHooksEnvVarsV1.Query(nil)
 	if err != nil {
-		log.Fatalln("Unable to query Smart Hook Environment Variables", err)
+		return err
 	}
 	for _, ev := range vars {
-		fmt.Println(*ev.Name, *ev.ID)
+		fmt.Fprintln(out, *ev.Name, *ev.ID)
 	}
+	return nil
 }
 
 func putEnvs(vars []string, client *client.APIClient) {
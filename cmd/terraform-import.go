@@ -5,12 +5,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/onelogin/onelogin/clients"
 	tfimport "github.com/onelogin/onelogin/terraform/import"
@@ -22,106 +25,229 @@ import (
 
 func init() {
 	var (
-		autoApprove *bool
-		outFile     *string
-		searchID    *string
-		clientList  *clients.Clients
+		autoApprove    *bool
+		outFile        *string
+		searchID       *string
+		list           *bool
+		importBlocks   *bool
+		generateConfig *string
+		force          *bool
+		clientList     *clients.Clients
+		fanOutFlags    fanOutInput
 	)
 	var tfImportCommand = &cobra.Command{
-		Use:   "terraform-import",
+		Use:   "terraform-import <type> [type...]",
 		Short: `Import resources to local Terraform state.`,
 		Long: `Uses Terraform Import to collect resources from a remote and
 		create new .tfstate and .tf files so you can begin managing existing resources with Terraform.
 		Available Imports:
-			onelogin_apps               => onelogin all apps
-			onelogin_saml_apps          => onelogin SAML apps only
-			onelogin_oidc_apps          => onelogin OIDC apps only
-			onelogin_user_mappings      => onelogin user mappings
-			onelogin_users              => onelogin users
-			onelogin_roles              => onelogin roles
-			onelogin_smarthooks         => onelogin smarthooks
-			onelogin_smarthook_env_vars => onelogin smarthook environment variables
-			okta_apps                   => okta apps
-			aws_iam_user                => aws users`,
-		Args: cobra.MinimumNArgs(1),
+` + importablesHelp() + `
+		--list shows the Terraform types, aliases and client of each.
+
+		Several types can be imported at once and "all" imports every OneLogin type, e.g.
+			onelogin terraform-import onelogin_users onelogin_roles
+			onelogin terraform-import all okta_apps
+		The output file defaults to <provider>.tf when every type is from one provider, and main.tf otherwise.
+
+		--import-blocks writes Terraform 1.5+ import blocks to imports.tf (or --output) instead of importing, so the
+		imports can be reviewed before any state is touched and work with remote backends. Apply them with terraform apply
+		once the resources have configuration. --generate-config[=file] also runs terraform plan -generate-config-out to
+		write that configuration, to generated.tf by default.
+
+		New resources are added after the output file's existing content, which is left as it is. An output file that
+		isn't Terraform configuration is refused unless --force is given, when it is replaced.
+
+		--profiles a,b,c or --all-profiles imports from several accounts concurrently, each into a sub-directory of the
+		working directory named after its profile. --auto_approve is required with either.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if *list {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 		PreRun: func(cmd *cobra.Command, args []string) {
+			if *list {
+				return
+			}
+			if fanOutFlags.enabled() {
+				if !*autoApprove {
+					log.Fatalln("--auto_approve is required with --profiles or --all-profiles")
+				}
+				return
+			}
 			configFile, err := os.OpenFile(viper.ConfigFileUsed(), os.O_RDWR, 0600)
 			if err != nil {
 				configFile.Close()
 				log.Println("Unable to open profiles file. Falling back to Environment Variables", err)
 			}
-			clientList = clients.New(configFile)
+			clientList = applyHTTPFlags(clients.New(configFile, selectedProfile()))
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			tfImport(args, clientList, *autoApprove, searchID, *outFile)
+			if *list {
+				listImportables(os.Stdout)
+				return
+			}
+			in := tfImportInput{
+				autoApprove:    *autoApprove,
+				searchID:       *searchID,
+				outFile:        *outFile,
+				importBlocks:   *importBlocks || *generateConfig != "",
+				generateConfig: *generateConfig,
+				force:          *force,
+			}
+			workingDir, _ := os.Getwd()
+			if fanOutFlags.enabled() {
+				fanOut(fanOutFlags.resolve(), func(name string, clientList *clients.Clients) error {
+					dir := filepath.Join(workingDir, name)
+					if err := os.MkdirAll(dir, 0750); err != nil {
+						return err
+					}
+					logger := log.New(os.Stderr, fmt.Sprintf("[%s] ", name), log.LstdFlags)
+					return tfImport(args, clientList, in, dir, logger)
+				})
+				return
+			}
+			if err := tfImport(args, clientList, in, workingDir, log.New(os.Stderr, "", log.LstdFlags)); err != nil {
+				log.Fatalln(err)
+			}
 		},
 	}
 	autoApprove = tfImportCommand.Flags().BoolP("auto_approve", "a", false, "Skip confirmation of resource import")
 	outFile = tfImportCommand.Flags().StringP("output", "o", "", "Output filename")
 	searchID = tfImportCommand.Flags().StringP("id", "i", "", "Import one resource by id")
+	list = tfImportCommand.Flags().Bool("list", false, "List the available imports")
+	importBlocks = tfImportCommand.Flags().Bool("import-blocks", false, "Write Terraform 1.5+ import blocks instead of running terraform import")
+	generateConfig = tfImportCommand.Flags().String("generate-config", "", "Write import blocks and generate configuration for them to this file with terraform plan")
+	tfImportCommand.Flags().Lookup("generate-config").NoOptDefVal = "generated.tf"
+	force = tfImportCommand.Flags().Bool("force", false, "Replace an output file that isn't Terraform configuration, or smart hook code files that differ")
+	fanOutFlags.register(tfImportCommand.Flags())
 	rootCmd.AddCommand(tfImportCommand)
 }
 
-func tfImport(args []string, clientList *clients.Clients, autoApprove bool, searchID *string, outFile string) {
-	sourceName := args[0]
-	workingDir, _ := os.Getwd()
-	if outFile == "" {
-		outFile = fmt.Sprintf("%s.tf", strings.Split(sourceName, "_")[0])
+// importablesHelp lists the registered importables by name for the command's help text
+func importablesHelp() string {
+	registrations := tfimportables.Registrations()
+	width := 0
+	for _, r := range registrations {
+		if len(r.Name) > width {
+			width = len(r.Name)
+		}
 	}
-	// #nosec G304 forcing the file to be created in the working directory
-	planFile, err := os.OpenFile(filepath.Join(workingDir, outFile), os.O_RDWR|os.O_CREATE, 0600)
-	if err != nil {
-		log.Fatalln("Unable to create desired tf file ", err)
+	out := strings.Builder{}
+	for _, r := range registrations {
+		fmt.Fprintf(&out, "\t\t\t%-*s => %s\n", width, r.Name, r.Description)
 	}
+	return out.String()
+}
 
-	pfReader, err := ioutil.ReadAll(planFile)
-	if err != nil {
-		planFile.Close()
-		log.Fatalln("Unable to read from tf file ", err)
+// listImportables prints a table of the registered importables
+func listImportables(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCLIENT\tTERRAFORM TYPES\tALIASES\tDESCRIPTION")
+	for _, r := range tfimportables.Registrations() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.Client, strings.Join(r.Types, ","), strings.Join(r.Aliases, ","), r.Description)
 	}
+	w.Flush()
+}
 
-	importables := tfimportables.New(clientList)
-	importable := importables.GetImportable(strings.ToLower(args[0]))
+// defaultOutFile names the output file after the provider of the imported types, or main.tf if they span several
+func defaultOutFile(registrations []tfimportables.Registration) string {
+	provider := strings.Split(registrations[0].Name, "_")[0]
+	for _, r := range registrations[1:] {
+		if strings.Split(r.Name, "_")[0] != provider {
+			return "main.tf"
+		}
+	}
+	return fmt.Sprintf("%s.tf", provider)
+}
+
+// summarizeResources counts the resources to import by type, e.g. "12 resources (3 onelogin_roles, 9 onelogin_users)"
+func summarizeResources(resourceDefinitions []tfimportables.ResourceDefinition) string {
+	counts := map[string]int{}
+	for _, resourceDefinition := range resourceDefinitions {
+		counts[resourceDefinition.Type]++
+	}
+	types := make([]string, 0, len(counts))
+	for resourceType := range counts {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+	for i, resourceType := range types {
+		types[i] = fmt.Sprintf("%d %s", counts[resourceType], resourceType)
+	}
+	return fmt.Sprintf("%d resources (%s)", len(resourceDefinitions), strings.Join(types, ", "))
+}
 
-	pfReader1 := bytes.NewReader(pfReader)
-	resourceDefinitionsFromRemote := importable.ImportFromRemote(searchID)
-	newResourceDefinitions, newProviderDefinitions := tfimport.DetermineNewResourcesAndProviders(pfReader1, resourceDefinitionsFromRemote)
+// tfImportInput collects the terraform-import flags that shape a run
+type tfImportInput struct {
+	autoApprove    bool
+	searchID       string
+	outFile        string
+	importBlocks   bool
+	generateConfig string // implies importBlocks
+	force          bool
+}
+
+func tfImport(sourceNames []string, clientList *clients.Clients, in tfImportInput, dir string, logger *log.Logger) error {
+	names := make([]string, len(sourceNames)) // sourceNames is shared by profiles importing concurrently
+	for i, name := range sourceNames {
+		names[i] = strings.ToLower(name)
+	}
+	registrations, err := tfimportables.Resolve(names)
+	if err != nil {
+		return err
+	}
+	if len(registrations) > 1 && in.searchID != "" {
+		return fmt.Errorf("--id can only be used when importing one type")
+	}
+	importables := tfimportables.New(clientList)
+	if in.importBlocks {
+		return writeImportBlocks(registrations, importables, in, dir, logger)
+	}
+	outFile := in.outFile
+	if outFile == "" {
+		outFile = defaultOutFile(registrations)
+	}
+	path := filepath.Join(dir, outFile)
+	original, clobber, err := readOutFile(path, in.force)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig(dir, path, clobber)
+	if err != nil {
+		return err
+	}
+	resourceDefinitionsFromRemote, err := collectResources(registrations, importables, in.searchID)
+	if err != nil {
+		return err
+	}
+	newResourceDefinitions, newProviderDefinitions := tfimport.DetermineNewResourcesAndProviders(config, resourceDefinitionsFromRemote)
 	if len(newResourceDefinitions) == 0 {
-		fmt.Println("No new resources to import from remote")
-		planFile.Close()
-		os.Exit(0)
-	}
-
-	if !autoApprove {
-		fmt.Printf("This will import %d resources. Do you want to continue? (y/n): ", len(newResourceDefinitions))
-		input := bufio.NewScanner(os.Stdin)
-		input.Scan()
-		text := strings.ToLower(input.Text())
-		if text != "y" && text != "yes" {
-			fmt.Printf("User aborted operation!")
-			if err := planFile.Close(); err != nil {
-				fmt.Println("Problem writing file", err)
-			}
-			os.Exit(0)
-		}
+		logger.Println("No new resources to import from remote")
+		return nil
 	}
 
-	pfReader2 := bytes.NewReader(pfReader)
-	newHCL := tfimport.AddNewProvidersAndResourceHCL(pfReader2, newResourceDefinitions, newProviderDefinitions)
+	if !in.autoApprove && !confirm(fmt.Sprintf("This will import %s.", summarizeResources(newResourceDefinitions))) {
+		return nil
+	}
+	if newProviderDefinitions, err = declareProviders(config, path, newProviderDefinitions, logger); err != nil {
+		return err
+	}
 
-	planFile.Seek(0, 0)
-	if _, err := planFile.Write([]byte(newHCL)); err != nil {
-		planFile.Close()
-		log.Fatal("Problem creating import file", err)
+	newHCL, err := tfimport.AddNewProvidersAndResourceHCL(bytes.NewReader(original), newResourceDefinitions, newProviderDefinitions)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, []byte(newHCL), 0600); err != nil {
+		return fmt.Errorf("problem creating import file: %s", err)
 	}
 
-	log.Println("Initializing Terraform with 'terraform init'...")
+	logger.Println("Initializing Terraform with 'terraform init'...")
 	// #nosec G204 running prescribed terraform command
-	if err := exec.Command("terraform", "init").Run(); err != nil {
-		if err := planFile.Close(); err != nil {
-			log.Fatal("Problem writing to tf file ", err)
-		}
-		log.Fatal("Problem executing terraform init", err)
+	initCmd := exec.Command("terraform", "init")
+	initCmd.Dir = dir
+	if err := initCmd.Run(); err != nil {
+		return fmt.Errorf("problem executing terraform init: %s", err)
 	}
 
 	for i, resourceDefinition := range newResourceDefinitions {
@@ -129,36 +255,315 @@ func tfImport(args []string, clientList *clients.Clients, autoApprove bool, sear
 		id := resourceDefinition.ImportID
 		// #nosec G204 running prescribed terraform command
 		cmd := exec.Command("terraform", "import", resourceName, id)
-		log.Printf("Importing resource %d", i+1)
+		cmd.Dir = dir
+		logger.Printf("Importing resource %d", i+1)
 		if err := cmd.Run(); err != nil {
-			log.Fatal("Problem executing terraform import", cmd.Args, err)
+			return fmt.Errorf("problem executing terraform import %v: %s", cmd.Args, err)
 		}
 	}
 
 	// grab the state from tfstate
 	state := stateparser.State{}
-	log.Println("Collecting State from tfstate File")
-	data, err := ioutil.ReadFile(filepath.Join("terraform.tfstate"))
+	logger.Println("Collecting State from tfstate File")
+	data, err := ioutil.ReadFile(filepath.Join(dir, "terraform.tfstate"))
 	if err != nil {
-		planFile.Close()
-		log.Fatalln("Unable to Read tfstate", err)
+		return fmt.Errorf("unable to read tfstate: %s", err)
 	}
 	if err := json.Unmarshal(data, &state); err != nil {
-		planFile.Close()
-		log.Fatalln("Unable to Translate tfstate in Memory", err)
+		return fmt.Errorf("unable to translate tfstate in memory: %s", err)
+	}
+
+	// only the imported resources are written, after the file's original content, so nothing already there changes
+	state.Resources = importedResources(state.Resources, newResourceDefinitions)
+	resourceHCL, secrets, files, err := stateparser.ConvertTFStateToResourceHCL(state, importables)
+	if err != nil {
+		return fmt.Errorf("unable to convert tfstate to hcl: %s", err)
+	}
+	if err := writeFiles(dir, files, in.force); err != nil {
+		return err
 	}
+	resourceHCL, err = stateparser.ReplaceIDsWithReferences(resourceHCL, importables,
+		stateparser.NewIDIndex(referenceableResources(config, dir, resourceDefinitionsFromRemote, newResourceDefinitions)))
+	if err != nil {
+		return fmt.Errorf("unable to replace ids with references: %s", err)
+	}
+	if len(secrets) > 0 {
+		if err := writeSecrets(dir, secrets, logger); err != nil {
+			return err
+		}
+		resourceHCL = append(append(resourceHCL, '\n'), secrets.VariablesHCL()...)
+	}
+	if err := ioutil.WriteFile(path, []byte(tfimport.MergeHCL(original, newProviderDefinitions, resourceHCL)), 0600); err != nil {
+		return fmt.Errorf("problem writing final tf file: %s", err)
+	}
+	return nil
+}
+
+// writeFiles writes the files the generated configuration reads attributes from, such as smart hook code, to dir.
+// A file that is already there with other content is refused unless force is set.
+func writeFiles(dir string, files stateparser.Files, force bool) error {
+	for filePath, content := range files {
+		path := filepath.Join(dir, filePath)
+		// #nosec G304 reading files in the working directory
+		if existing, err := ioutil.ReadFile(path); err == nil && !bytes.Equal(existing, content) && !force {
+			return fmt.Errorf("%s already exists with other content, so it was left alone. Use --force to replace it", filePath)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			return fmt.Errorf("unable to create %s: %s", filepath.Dir(filePath), err)
+		}
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			return fmt.Errorf("problem writing %s: %s", filePath, err)
+		}
+	}
+	return nil
+}
 
-	buffer := stateparser.ConvertTFStateToHCL(state, importables)
+// secretsFile holds the values of the sensitive variables. Terraform loads .auto.tfvars files itself.
+const secretsFile = "secrets.auto.tfvars"
 
-	// go to the start of main.tf and overwrite whole file
-	planFile.Seek(0, 0)
-	_, err = planFile.Write(buffer)
+// writeSecrets adds the sensitive values to the secrets file in dir and has git ignore it, so they aren't committed
+func writeSecrets(dir string, secrets stateparser.Secrets, logger *log.Logger) error {
+	path := filepath.Join(dir, secretsFile)
+	// #nosec G304 reading the secrets file in the working directory
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read %s: %s", secretsFile, err)
+	}
+	tfvars, err := secrets.TFVars(existing)
 	if err != nil {
-		planFile.Close()
-		fmt.Println("ERROR Writing Final tf file ", err)
+		return fmt.Errorf("unable to add to %s: %s", secretsFile, err)
+	}
+	if err := ioutil.WriteFile(path, tfvars, 0600); err != nil {
+		return fmt.Errorf("problem writing %s: %s", secretsFile, err)
 	}
+	if err := gitIgnore(dir, secretsFile); err != nil {
+		return fmt.Errorf("unable to add %s to .gitignore: %s", secretsFile, err)
+	}
+	logger.Printf("Wrote %d sensitive values to %s and added it to .gitignore. Keep it out of version control", len(secrets), secretsFile)
+	return nil
+}
 
-	if err := planFile.Close(); err != nil {
-		fmt.Println("Problem writing file", err)
+// gitIgnore adds the file to the .gitignore in dir unless it is already listed
+func gitIgnore(dir string, name string) error {
+	path := filepath.Join(dir, ".gitignore")
+	// #nosec G304 reading the .gitignore in the working directory
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == name || line == "/"+name || line == "*.tfvars" || line == "*.auto.tfvars" {
+			return nil
+		}
+	}
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	// #nosec G306 .gitignore holds no secrets and is meant to be shared
+	return ioutil.WriteFile(path, append(content, name+"\n"...), 0644)
+}
+
+// readOutFile returns the content of the output file to add to. A file that is not Terraform configuration is
+// refused, as it can't be added to safely, unless force is set, when clobber is returned and its content is replaced.
+func readOutFile(path string, force bool) (content []byte, clobber bool, err error) {
+	// #nosec G304 forcing the file to be created in the working directory
+	content, err = ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to read from tf file: %s", err)
+	}
+	if _, err := tfimport.ParseConfig(content, path); err != nil {
+		if !force {
+			return nil, false, fmt.Errorf("%s has content that isn't Terraform configuration, so it was left alone. Use --force to replace it: %s", path, err)
+		}
+		return nil, true, nil
+	}
+	return content, false, nil
+}
+
+// loadConfig loads the configuration in dir, leaving out the output file if it is to be replaced
+func loadConfig(dir string, path string, clobber bool) (*tfimport.Config, error) {
+	if clobber {
+		return tfimport.LoadConfig(dir, path)
+	}
+	return tfimport.LoadConfig(dir)
+}
+
+// declareProviders adds the new providers to the root module's required_providers block if it is in a file other
+// than the output file, as a module can only have one, and returns those left for the output file to declare
+func declareProviders(config *tfimport.Config, outPath string, providers []string, logger *log.Logger) ([]string, error) {
+	if len(providers) == 0 || config.RequiredProviders == "" {
+		return providers, nil
+	}
+	declaredIn, err := filepath.Abs(config.RequiredProviders)
+	if err != nil {
+		return nil, err
+	}
+	if out, err := filepath.Abs(outPath); err != nil || out == declaredIn {
+		return providers, err
+	}
+	info, err := os.Stat(declaredIn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", config.RequiredProviders, err)
+	}
+	// #nosec G304 reading the configuration in the working directory
+	src, err := ioutil.ReadFile(declaredIn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", config.RequiredProviders, err)
+	}
+	updated, ok := tfimport.AddRequiredProviders(src, providers)
+	if !ok {
+		return providers, nil
+	}
+	if err := ioutil.WriteFile(declaredIn, updated, info.Mode()); err != nil {
+		return nil, fmt.Errorf("problem writing %s: %s", config.RequiredProviders, err)
+	}
+	logger.Printf("Added %s to the required providers in %s", strings.Join(providers, ", "), filepath.Base(declaredIn))
+	return nil, nil
+}
+
+// collectResources gathers the resource definitions of every requested importable from its remote
+func collectResources(registrations []tfimportables.Registration, importables *tfimportables.ImportableList, searchID string) ([]tfimportables.ResourceDefinition, error) {
+	resourceDefinitions := []tfimportables.ResourceDefinition{}
+	for _, registration := range registrations {
+		importable, err := importables.Importable(registration.Name)
+		if err != nil {
+			return nil, err
+		}
+		remoteDefinitions, err := importable.ImportFromRemote(&searchID)
+		if err != nil {
+			return nil, err
+		}
+		resourceDefinitions = append(resourceDefinitions, remoteDefinitions...)
+	}
+	return resourceDefinitions, nil
+}
+
+// checkGenerateConfig refuses to have terraform generate configuration for importables with sensitive attributes, as it
+// would write their values inline instead of to variables
+func checkGenerateConfig(registrations []tfimportables.Registration, importables *tfimportables.ImportableList) error {
+	for _, registration := range registrations {
+		importable, err := importables.Importable(registration.Name)
+		if err != nil {
+			return err
+		}
+		if stateparser.HasSensitiveFields(importable.HCLShape()) {
+			return fmt.Errorf("--generate-config would write the sensitive values of %s inline. Import them without --generate-config to keep those in variables", registration.Name)
+		}
+	}
+	return nil
+}
+
+// confirm asks the user to continue and reports whether they agreed
+func confirm(prompt string) bool {
+	fmt.Printf("%s Do you want to continue? (y/n): ", prompt)
+	input := bufio.NewScanner(os.Stdin)
+	input.Scan()
+	text := strings.ToLower(input.Text())
+	if text != "y" && text != "yes" {
+		fmt.Printf("User aborted operation!")
+		return false
+	}
+	return true
+}
+
+// writeImportBlocks appends import blocks for the remote resources not yet defined or imported by the configuration in dir
+// to the output file, leaving state alone, then generates their configuration with terraform plan if asked
+func writeImportBlocks(registrations []tfimportables.Registration, importables *tfimportables.ImportableList, in tfImportInput, dir string, logger *log.Logger) error {
+	outFile := in.outFile
+	if outFile == "" {
+		outFile = "imports.tf"
+	}
+	if in.generateConfig != "" {
+		if _, err := os.Stat(filepath.Join(dir, in.generateConfig)); err == nil {
+			return fmt.Errorf("%s already exists and terraform will only generate configuration to a new file", in.generateConfig)
+		}
+		if err := checkGenerateConfig(registrations, importables); err != nil {
+			return err
+		}
+	}
+	path := filepath.Join(dir, outFile)
+	original, clobber, err := readOutFile(path, in.force)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig(dir, path, clobber)
+	if err != nil {
+		return err
+	}
+
+	resourceDefinitionsFromRemote, err := collectResources(registrations, importables, in.searchID)
+	if err != nil {
+		return err
+	}
+	newResourceDefinitions, newProviderDefinitions := tfimport.DetermineNewResourcesAndProviders(config, resourceDefinitionsFromRemote)
+	if len(newResourceDefinitions) == 0 {
+		logger.Println("No new resources to import from remote")
+		return nil
+	}
+	prompt := fmt.Sprintf("This will write import blocks for %s to %s.", summarizeResources(newResourceDefinitions), outFile)
+	if !in.autoApprove && !confirm(prompt) {
+		return nil
+	}
+
+	if newProviderDefinitions, err = declareProviders(config, path, newProviderDefinitions, logger); err != nil {
+		return err
+	}
+	importHCL := tfimport.ImportBlocksHCL(newResourceDefinitions)
+	if err := ioutil.WriteFile(path, []byte(tfimport.MergeHCL(original, newProviderDefinitions, []byte(importHCL))), 0600); err != nil {
+		return fmt.Errorf("problem writing import file: %s", err)
+	}
+	logger.Printf("Wrote %d import blocks to %s", len(newResourceDefinitions), outFile)
+	if in.generateConfig == "" {
+		logger.Println("Add configuration for them, or run 'terraform plan -generate-config-out=generated.tf' to generate it, then review and run 'terraform apply' to import")
+		return nil
+	}
+
+	logger.Println("Initializing Terraform with 'terraform init'...")
+	// #nosec G204 running prescribed terraform command
+	initCmd := exec.Command("terraform", "init")
+	initCmd.Dir = dir
+	if out, err := initCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("problem executing terraform init: %s\n%s", err, out)
+	}
+	logger.Printf("Generating configuration with 'terraform plan -generate-config-out=%s'...", in.generateConfig)
+	// #nosec G204 running prescribed terraform command
+	planCmd := exec.Command("terraform", "plan", "-generate-config-out="+in.generateConfig)
+	planCmd.Dir = dir
+	if out, err := planCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("problem executing terraform plan, fix %s and run it again: %s\n%s", in.generateConfig, err, out)
+	}
+	logger.Printf("Wrote configuration to %s. Review it and %s, then run 'terraform apply' to import", in.generateConfig, outFile)
+	return nil
+}
+
+// referenceableResources lists the remote resources that can be referred to from the output file: the ones just
+// imported and the ones with resource blocks in the working directory. Those in modules can't be referred to by address.
+func referenceableResources(config *tfimport.Config, dir string, resourceDefinitionsFromRemote, newResourceDefinitions []tfimportables.ResourceDefinition) []tfimportables.ResourceDefinition {
+	referenceable := append([]tfimportables.ResourceDefinition{}, newResourceDefinitions...)
+	for _, resourceDefinition := range resourceDefinitionsFromRemote {
+		file, ok := config.Resources[fmt.Sprintf("%s.%s", resourceDefinition.Type, resourceDefinition.Name)]
+		if ok && filepath.Dir(file) == filepath.Clean(dir) {
+			referenceable = append(referenceable, resourceDefinition)
+		}
+	}
+	return referenceable
+}
+
+// importedResources keeps the state resources that were just imported
+func importedResources(resources []stateparser.StateResource, newResourceDefinitions []tfimportables.ResourceDefinition) []stateparser.StateResource {
+	imported := map[string]bool{}
+	for _, resourceDefinition := range newResourceDefinitions {
+		imported[fmt.Sprintf("%s.%s", resourceDefinition.Type, resourceDefinition.Name)] = true
+	}
+	kept := []stateparser.StateResource{}
+	for _, resource := range resources {
+		if resource.Module == "" && imported[fmt.Sprintf("%s.%s", resource.Type, resource.Name)] {
+			kept = append(kept, resource)
+		}
 	}
+	return kept
 }
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/onelogin/onelogin/clients"
+	tfimportables "github.com/onelogin/onelogin/terraform/importables"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckGenerateConfig(t *testing.T) {
+	clientList := &clients.Clients{
+		ClientConfigs: clients.ClientConfigs{
+			OneLoginClientID:     "ONELOGIN_CLIENT_ID",
+			OneLoginClientSecret: "ONELOGIN_CLIENT_SECRET",
+			OneLoginURL:          "ONELOGIN_OAPI_URL",
+			OktaOrgName:          "test",
+			OktaBaseURL:          "test.com",
+			OktaAPIToken:         "test",
+		},
+	}
+	tests := map[string]struct {
+		Names         []string
+		ExpectedError string
+	}{
+		"It allows importables without sensitive attributes": {
+			Names: []string{"onelogin_apps", "onelogin_roles"},
+		},
+		"It refuses importables with sensitive attributes": {
+			Names:         []string{"onelogin_roles", "onelogin_smarthook_env_vars"},
+			ExpectedError: "--generate-config would write the sensitive values of onelogin_smarthook_env_vars inline. Import them without --generate-config to keep those in variables",
+		},
+		"It refuses importables with sensitive attributes in nested objects": {
+			Names:         []string{"okta_apps"},
+			ExpectedError: "--generate-config would write the sensitive values of okta_apps inline. Import them without --generate-config to keep those in variables",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			registrations := []tfimportables.Registration{}
+			for _, name := range test.Names {
+				registration, ok := tfimportables.Lookup(name)
+				assert.True(t, ok)
+				registrations = append(registrations, registration)
+			}
+			err := checkGenerateConfig(registrations, tfimportables.New(clientList))
+			if test.ExpectedError == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, test.ExpectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// onelogin-credential-pass is a reference credential helper for the OneLogin CLI backed by pass (https://www.passwordstore.org).
+//
+// The CLI runs the helper as `onelogin-credential-pass get`, writes a JSON request like
+// {"profile":"prod","region":"us","client_id":"..."} to its stdin and expects
+// {"client_id":"...","client_secret":"..."} on stdout.
+//
+// Credentials are read from the pass entry onelogin/<profile>. Following the pass convention, the first line
+// of the entry is the client secret and the client id is given on a "client_id: <id>" line, e.g.
+//
+//	pass insert -m onelogin/prod
+//	s3cr3t
+//	client_id: 0123456789abcdef
+//
+// Install with `go install ./credential-helpers/onelogin-credential-pass` and point a profile at it with
+// `onelogin profiles add prod --region us --credential-helper pass`.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type request struct {
+	Profile  string `json:"profile"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+type response struct {
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret"`
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "get" {
+		// store and erase are not supported, manage the entries with pass directly
+		os.Exit(0)
+	}
+	req := request{}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fail("unable to read request", err)
+	}
+	if req.Profile == "" {
+		fail("request did not name a profile", nil)
+	}
+
+	var entry bytes.Buffer
+	// #nosec G204 the entry name is derived from the profile name
+	cmd := exec.Command("pass", "show", fmt.Sprintf("onelogin/%s", req.Profile))
+	cmd.Stdout = &entry
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fail("unable to read pass entry", err)
+	}
+
+	resp := response{ClientID: req.ClientID}
+	scanner := bufio.NewScanner(&entry)
+	for line := 0; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if line == 0 {
+			resp.ClientSecret = text
+		} else if strings.HasPrefix(text, "client_id:") {
+			resp.ClientID = strings.TrimSpace(strings.TrimPrefix(text, "client_id:"))
+		}
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(resp); err != nil {
+		fail("unable to write response", err)
+	}
+}
+
+func fail(msg string, err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "onelogin-credential-pass:", msg, err)
+	} else {
+		fmt.Fprintln(os.Stderr, "onelogin-credential-pass:", msg)
+	}
+	os.Exit(1)
+}
@@ -4,6 +4,7 @@ go 1.13
 
 require (
 	github.com/aws/aws-sdk-go v1.34.0
+	github.com/hashicorp/hcl/v2 v2.12.0
 	github.com/jpoles1/gopherbadger v2.4.0+incompatible // indirect
 	github.com/lunixbochs/vtclean v1.0.0 // indirect
 	github.com/manifoldco/promptui v0.8.0
@@ -12,7 +13,10 @@ require (
 	github.com/okta/okta-sdk-golang/v2 v2.0.0
 	github.com/onelogin/onelogin-go-sdk v1.1.17
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.4.0
 	github.com/stretchr/testify v1.5.1
-	golang.org/x/sys v0.0.0-20210521090106-6ca3eb03dfc2 // indirect
+	github.com/zclconf/go-cty v1.8.0
+	golang.org/x/crypto v0.0.0-20200221231518-2aa609cf4a9d
+	golang.org/x/sys v0.0.0-20210521090106-6ca3eb03dfc2
 )
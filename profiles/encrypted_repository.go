@@ -0,0 +1,175 @@
+package profiles
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// PassphraseEnvVar names the environment variable consulted for the profiles passphrase
+// before falling back to a terminal prompt
+const PassphraseEnvVar = "ONELOGIN_PROFILES_PASSPHRASE"
+
+// scrypt cost parameters for newly encrypted files. Existing files carry their own parameters.
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+var additionalData = []byte("onelogin-profiles-v1")
+
+var errDecrypt = errors.New("incorrect passphrase or corrupted profiles file")
+
+// EncryptedFileRepository stores profiles in the same file as FileRepository but encrypts them at rest
+// with AES-GCM using a key derived from Passphrase via scrypt
+type EncryptedFileRepository struct {
+	StorageMedia *os.File
+	Passphrase   []byte
+}
+
+// encryptedFile is the on-disk layout of an encrypted profiles file
+type encryptedFile struct {
+	Encryption *encryptionParams `json:"encryption"`
+	Ciphertext []byte            `json:"ciphertext"`
+}
+
+type encryptionParams struct {
+	KDF   string `json:"kdf"`
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+}
+
+// IsEncrypted reports whether the given profiles file contents were written by an EncryptedFileRepository
+func IsEncrypted(data []byte) bool {
+	envelope := encryptedFile{}
+	if err := json.Unmarshal(bytes.Trim(data, "\x00"), &envelope); err != nil {
+		return false
+	}
+	return envelope.Encryption != nil && envelope.Encryption.KDF == "scrypt" && len(envelope.Ciphertext) > 0
+}
+
+func (p EncryptedFileRepository) readAll() ([]byte, error) {
+	data, err := readProfiles(p.StorageMedia)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.Trim(data, "\x00")
+	if len(data) == 0 {
+		return data, nil
+	}
+	return decryptProfiles(data, p.Passphrase)
+}
+
+func (p EncryptedFileRepository) persist(profiles map[string]*Profile) {
+	content, err := encryptProfiles(marshalProfiles(profiles), p.Passphrase)
+	if err != nil {
+		p.StorageMedia.Close()
+		log.Fatalln("Unable to encrypt profiles", err)
+	}
+	writeProfiles(p.StorageMedia, content)
+}
+
+func (p EncryptedFileRepository) lock() func() {
+	return lockProfiles(p.StorageMedia)
+}
+
+func encryptProfiles(plaintext, passphrase []byte) ([]byte, error) {
+	params := &encryptionParams{KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP, Salt: make([]byte, 16)}
+	if _, err := io.ReadFull(rand.Reader, params.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	params.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, params.Nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(encryptedFile{
+		Encryption: params,
+		Ciphertext: aead.Seal(nil, params.Nonce, plaintext, additionalData),
+	})
+}
+
+func decryptProfiles(data, passphrase []byte) ([]byte, error) {
+	envelope := encryptedFile{}
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Encryption == nil {
+		return nil, errors.New("profiles file is not encrypted")
+	}
+	aead, err := newAEAD(passphrase, envelope.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Encryption.Nonce) != aead.NonceSize() {
+		return nil, errDecrypt
+	}
+	plaintext, err := aead.Open(nil, envelope.Encryption.Nonce, envelope.Ciphertext, additionalData)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return plaintext, nil
+}
+
+func newAEAD(passphrase []byte, params *encryptionParams) (cipher.AEAD, error) {
+	if params.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation function %q", params.KDF)
+	}
+	key, err := scrypt.Key(passphrase, params.Salt, params.N, params.R, params.P, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ReadPassphrase returns the profiles passphrase from the environment or prompts for it on the terminal.
+// When confirm is set the user is asked to type a new passphrase twice.
+func ReadPassphrase(confirm bool) []byte {
+	if passphrase := os.Getenv(PassphraseEnvVar); passphrase != "" {
+		return []byte(passphrase)
+	}
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		log.Fatalf("Profiles passphrase required. Set %s or run from a terminal\n", PassphraseEnvVar)
+	}
+	fmt.Fprint(os.Stderr, "Profiles passphrase: ")
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		log.Fatalln("Unable to read passphrase", err)
+	}
+	if len(passphrase) == 0 {
+		log.Fatalln("Passphrase cannot be blank!")
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			log.Fatalln("Unable to read passphrase", err)
+		}
+		if !bytes.Equal(passphrase, again) {
+			log.Fatalln("Passphrases do not match!")
+		}
+	}
+	return passphrase
+}
@@ -0,0 +1,136 @@
+package profiles
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tempProfilesFile writes a profiles.json in a fresh directory so backups and lock files are cleaned up with it
+func tempProfilesFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "profiles.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func openProfilesFile(t *testing.T, path string) *os.File {
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestEncryptedFileRepository(t *testing.T) {
+	tests := map[string]struct {
+		Plaintext         string
+		ReadPassphrase    string
+		ExpectedProfile   *Profile
+		ExpectedReadError bool
+	}{
+		"It encrypts profiles and reads them back with the same passphrase": {
+			Plaintext:       `{"t":{"name":"t","active":true,"region":"us","client_id":"ti","client_secret":"plaintext-secret"}}`,
+			ReadPassphrase:  "correct horse",
+			ExpectedProfile: &Profile{Name: "t", Active: true, Region: "us", ClientID: "ti", ClientSecret: "plaintext-secret"},
+		},
+		"It refuses to read profiles with the wrong passphrase": {
+			Plaintext:         `{"t":{"name":"t","active":true,"region":"us","client_id":"ti","client_secret":"plaintext-secret"}}`,
+			ReadPassphrase:    "battery staple",
+			ExpectedReadError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := tempProfilesFile(t, test.Plaintext)
+			defer os.RemoveAll(filepath.Dir(path))
+
+			plaintextSvc := ProfileService{Repository: FileRepository{StorageMedia: openProfilesFile(t, path)}}
+			plaintextSvc.MigrateTo(EncryptedFileRepository{StorageMedia: openProfilesFile(t, path), Passphrase: []byte("correct horse")})
+
+			content, _ := ioutil.ReadFile(path)
+			assert.True(t, IsEncrypted(content))
+			assert.NotContains(t, string(content), "plaintext-secret")
+
+			repository := EncryptedFileRepository{StorageMedia: openProfilesFile(t, path), Passphrase: []byte(test.ReadPassphrase)}
+			defer repository.StorageMedia.Close()
+			if test.ExpectedReadError {
+				_, err := repository.readAll()
+				assert.Equal(t, errDecrypt, err)
+			} else {
+				encryptedSvc := ProfileService{Repository: repository}
+				assert.Equal(t, test.ExpectedProfile, encryptedSvc.Find("t"))
+			}
+		})
+	}
+}
+
+func TestMigrateToEncryptedLeavesNoPlaintext(t *testing.T) {
+	path := tempProfilesFile(t, `{"t":{"name":"t","active":true,"region":"us","client_id":"ti","client_secret":"super-secret-value"}}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	// an update while the file is plaintext leaves a plaintext backup behind
+	plaintextSvc := ProfileService{Repository: FileRepository{StorageMedia: openProfilesFile(t, path)}}
+	plaintextSvc.Activate("t")
+	backup, err := ioutil.ReadFile(path + ".bak")
+	assert.Nil(t, err)
+	assert.Contains(t, string(backup), "super-secret-value")
+
+	plaintextSvc = ProfileService{Repository: FileRepository{StorageMedia: openProfilesFile(t, path)}}
+	plaintextSvc.MigrateTo(EncryptedFileRepository{StorageMedia: openProfilesFile(t, path), Passphrase: []byte("correct horse")})
+
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(filepath.Join(filepath.Dir(path), file.Name()))
+		assert.Nil(t, err)
+		assert.NotContains(t, string(content), "super-secret-value", file.Name())
+	}
+
+	// later writes back up the encrypted contents
+	encryptedSvc := ProfileService{Repository: EncryptedFileRepository{StorageMedia: openProfilesFile(t, path), Passphrase: []byte("correct horse")}}
+	encryptedSvc.Activate("t")
+	backup, err = ioutil.ReadFile(path + ".bak")
+	assert.Nil(t, err)
+	assert.True(t, IsEncrypted(backup))
+}
+
+func TestOpenRepository(t *testing.T) {
+	tests := map[string]struct {
+		Content  string
+		Expected Repository
+	}{
+		"It opens plaintext files with a FileRepository": {
+			Content:  `{"t":{"name":"t","active":true,"region":"us","client_id":"ti","client_secret":"ts"}}`,
+			Expected: FileRepository{},
+		},
+		"It opens empty files with a FileRepository": {
+			Content:  "",
+			Expected: FileRepository{},
+		},
+		"It opens encrypted files with an EncryptedFileRepository": {
+			Content:  `{"encryption":{"kdf":"scrypt","n":32768,"r":8,"p":1,"salt":"c2FsdA==","nonce":"bm9uY2U="},"ciphertext":"Y2lwaGVy"}`,
+			Expected: EncryptedFileRepository{},
+		},
+	}
+	os.Setenv(PassphraseEnvVar, "test")
+	defer os.Unsetenv(PassphraseEnvVar)
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := tempProfilesFile(t, test.Content)
+			defer os.RemoveAll(filepath.Dir(path))
+			f := openProfilesFile(t, path)
+			defer f.Close()
+			repository := OpenRepository(f)
+			assert.IsType(t, test.Expected, repository)
+		})
+	}
+}
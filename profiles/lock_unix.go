@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package profiles
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFileExclusive(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
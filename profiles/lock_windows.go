@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package profiles
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lock the first byte, which is enough to serialize processes cooperating on the same lock file
+func lockFileExclusive(f *os.File) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{})
+}
+
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &windows.Overlapped{})
+}
@@ -1,39 +1,166 @@
 package profiles
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 )
 
+// SchemaVersion is the version of the profiles file layout written by this build.
+// Version 1 files are a bare map of profile name to profile and are migrated forward on the next write.
+const SchemaVersion = 2
+
 type Repository interface {
 	persist(profiles map[string]*Profile)
 	readAll() ([]byte, error)
+	lock() (unlock func()) // held around read-modify-write cycles so concurrent runs don't lose each other's changes
 }
 
+// FileRepository stores profiles as JSON in the given file. Writes go to a temporary file that is renamed over
+// the original, keeping the previous version as a .bak file, so readers never see a partially written file.
 type FileRepository struct {
 	StorageMedia *os.File
 }
 
+// profilesFile is the versioned layout of the profiles file
+type profilesFile struct {
+	SchemaVersion int                 `json:"schema_version"`
+	Profiles      map[string]*Profile `json:"profiles"`
+}
+
+// OpenRepository inspects the profiles file and returns the Repository that can read it.
+// Encrypted files are opened with a passphrase taken from the environment or the terminal.
+func OpenRepository(file *os.File) Repository {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		log.Fatalln("Unable to read profiles", err)
+	}
+	if _, err = file.Seek(0, 0); err != nil {
+		log.Fatalln("Unable to read profiles", err)
+	}
+	if IsEncrypted(data) {
+		return EncryptedFileRepository{StorageMedia: file, Passphrase: ReadPassphrase(false)}
+	}
+	return FileRepository{StorageMedia: file}
+}
+
 func (p FileRepository) readAll() ([]byte, error) {
-	return ioutil.ReadAll(p.StorageMedia)
+	return readProfiles(p.StorageMedia)
 }
 
 func (p FileRepository) persist(profiles map[string]*Profile) {
-	data := map[string]Profile{}
-	for n, prf := range profiles {
-		data[n] = *prf
-	}
+	writeProfiles(p.StorageMedia, marshalProfiles(profiles))
+}
+
+func (p FileRepository) lock() func() {
+	return lockProfiles(p.StorageMedia)
+}
+
+func marshalProfiles(profiles map[string]*Profile) []byte {
+	data := profilesFile{SchemaVersion: SchemaVersion, Profiles: profiles}
 	updatedProfiles, _ := json.Marshal(data)
-	p.StorageMedia.Truncate(0)
-	if _, err := p.StorageMedia.WriteAt(updatedProfiles, 0); err != nil {
-		if err = p.StorageMedia.Close(); err != nil {
-			log.Fatalln("Unable write profile", err)
+	return updatedProfiles
+}
+
+// unmarshalProfiles reads any known version of the profiles file layout
+func unmarshalProfiles(data []byte) (map[string]*Profile, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	version := 1
+	if raw, ok := fields["schema_version"]; ok {
+		// a profile named schema_version would be an object, never a number
+		json.Unmarshal(raw, &version)
+	}
+	switch {
+	case version > SchemaVersion:
+		return nil, fmt.Errorf("profiles file uses schema version %d but this version of the CLI only supports up to %d. Please upgrade", version, SchemaVersion)
+	case version == 1:
+		profiles := map[string]*Profile{}
+		err := json.Unmarshal(data, &profiles)
+		return profiles, err
+	default:
+		file := profilesFile{}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, err
+		}
+		if file.Profiles == nil {
+			file.Profiles = map[string]*Profile{}
 		}
+		return file.Profiles, nil
+	}
+}
+
+// readProfiles reads the profiles file by name rather than through the handle so that a
+// version renamed into place by another process after the handle was opened is picked up
+func readProfiles(file *os.File) ([]byte, error) {
+	data, err := ioutil.ReadFile(file.Name())
+	if os.IsNotExist(err) {
+		return []byte{}, nil
+	}
+	return data, err
+}
+
+// lockProfiles takes an exclusive advisory lock on a sidecar .lock file next to the profiles file,
+// blocking until any other process holding it is done
+func lockProfiles(file *os.File) func() {
+	// #nosec G304 the lock file lives next to the profiles file
+	lockFile, err := os.OpenFile(file.Name()+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		log.Fatalln("Unable to lock profiles file", err)
+	}
+	if err := lockFileExclusive(lockFile); err != nil {
+		lockFile.Close()
+		log.Fatalln("Unable to lock profiles file", err)
+	}
+	return func() {
+		unlockFile(lockFile)
+		lockFile.Close()
+	}
+}
+
+// writeProfiles atomically replaces the contents of the profiles file, keeps the previous contents
+// as a backup and closes the handle. When plaintext profiles are replaced by encrypted ones, the plaintext
+// is not kept and any older backup, which would also be plaintext, is removed.
+func writeProfiles(file *os.File, content []byte) {
+	path := file.Name()
+	file.Close()
+	if previous, err := ioutil.ReadFile(path); err == nil && len(bytes.Trim(previous, "\x00")) > 0 {
+		if IsEncrypted(content) && !IsEncrypted(previous) {
+			if err := os.Remove(path + ".bak"); err != nil && !os.IsNotExist(err) {
+				log.Fatalln("Unable to remove plaintext profiles backup", err)
+			}
+		} else if err := replaceFile(path+".bak", previous); err != nil {
+			log.Fatalln("Unable to back up profiles", err)
+		}
+	}
+	if err := replaceFile(path, content); err != nil {
 		log.Fatalln("Unable to persist", err)
 	}
-	if err := p.StorageMedia.Close(); err != nil {
-		log.Fatalln("Unable write profile", err)
+}
+
+// replaceFile writes content to a temporary file in the same directory and renames it over path
+func replaceFile(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed. TempFile creates files with 0600 permissions
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
 	}
+	return os.Rename(tmp.Name(), path)
 }
@@ -0,0 +1,69 @@
+package profiles
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalProfiles(t *testing.T) {
+	tests := map[string]struct {
+		Content       string
+		Expected      map[string]*Profile
+		ExpectedError bool
+	}{
+		"It reads version 1 files": {
+			Content:  `{"t":{"name":"t","active":true,"region":"us","client_id":"ti","client_secret":"ts"}}`,
+			Expected: map[string]*Profile{"t": &Profile{Name: "t", Active: true, Region: "us", ClientID: "ti", ClientSecret: "ts"}},
+		},
+		"It reads version 2 files": {
+			Content:  `{"schema_version":2,"profiles":{"t":{"name":"t","active":true,"region":"us","client_id":"ti","client_secret":"ts"}}}`,
+			Expected: map[string]*Profile{"t": &Profile{Name: "t", Active: true, Region: "us", ClientID: "ti", ClientSecret: "ts"}},
+		},
+		"It reads version 2 files with no profiles": {
+			Content:  `{"schema_version":2}`,
+			Expected: map[string]*Profile{},
+		},
+		"It refuses files from a newer version of the CLI": {
+			Content:       `{"schema_version":3,"profiles":{}}`,
+			ExpectedError: true,
+		},
+		"It refuses corrupt files": {
+			Content:       `{"t":{"name":"t"`,
+			ExpectedError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			profiles, err := unmarshalProfiles([]byte(test.Content))
+			if test.ExpectedError {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, test.Expected, profiles)
+			}
+		})
+	}
+}
+
+func TestFileRepositoryPersist(t *testing.T) {
+	original := `{"t":{"name":"t","active":true,"region":"us","client_id":"ti","client_secret":"ts"}}`
+	path := tempProfilesFile(t, original)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	svc := ProfileService{Repository: FileRepository{StorageMedia: openProfilesFile(t, path)}}
+	svc.Activate("t")
+
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(t, `{"schema_version":2,"profiles":{"t":{"name":"t","active":true,"region":"us","client_id":"ti","client_secret":"ts"}}}`, string(content))
+	backup, _ := ioutil.ReadFile(path + ".bak")
+	assert.Equal(t, original, string(backup))
+
+	entries, _ := ioutil.ReadDir(filepath.Dir(path))
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".tmp", "temporary files should be renamed into place")
+	}
+}
@@ -3,24 +3,117 @@ package profiles
 import (
 	"bufio"
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/url"
+	"regexp"
 	"strings"
 )
 
+var subdomainRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]*[a-zA-Z0-9])?$`)
+
 type ProfileService struct {
-	Repository  Repository
-	InputReader io.Reader
+	Repository     Repository
+	InputReader    io.Reader
+	Preset         *Profile            // values supplied outside the prompts (e.g. flags). Non-empty fields are applied on Create and Update
+	NonInteractive bool                // never prompt. Create and Update fail if the preset leaves the profile incomplete
+	Verifier       func(Profile) error // optional check run before Create and Update save. The profile is not saved if it fails
 }
 
 type Profile struct {
-	Name         string `json:"name"`
-	Active       bool   `json:"active"`
-	Region       string `json:"region"`
-	ClientID     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
+	Name             string        `json:"name"`
+	Active           bool          `json:"active"`
+	Region           string        `json:"region"`
+	ClientID         string        `json:"client_id"`
+	ClientSecret     string        `json:"client_secret"`
+	CredentialHelper string        `json:"credential_helper,omitempty"` // executable that supplies the client id and secret at runtime
+	APIURL           string        `json:"api_url,omitempty"`           // explicit API base URL e.g. a shard, staging stack or local mock server
+	Subdomain        string        `json:"subdomain,omitempty"`         // tenant subdomain, used to address the API as https://<subdomain>.onelogin.com
+	Okta             *OktaSource   `json:"okta,omitempty"`              // optional Okta org to import from. Overrides the OKTA_* environment variables
+	AWS              *AWSSource    `json:"aws,omitempty"`               // optional AWS account to import from. Overrides AWS_REGION
+	HTTP             *HTTPSettings `json:"http,omitempty"`              // optional timeout and retry settings. Overrides ONELOGIN_TIMEOUT and ONELOGIN_MAX_RETRIES
+}
+
+// OktaSource holds the credentials for an Okta org used as an import source
+type OktaSource struct {
+	OrgName  string `json:"org_name,omitempty"`
+	BaseURL  string `json:"base_url,omitempty"`
+	APIToken string `json:"api_token,omitempty"`
+}
+
+// AWSSource selects the AWS account used as an import source
+type AWSSource struct {
+	Region  string `json:"region,omitempty"`
+	Profile string `json:"profile,omitempty"` // named profile from the AWS shared config and credentials files
+}
+
+// HTTPSettings tunes how requests to the profile's remotes are made
+type HTTPSettings struct {
+	Timeout    int    `json:"timeout,omitempty"`     // seconds to wait for each response
+	MaxRetries *int   `json:"max_retries,omitempty"` // times to retry rate limited and failed requests. 0 disables retries
+	Proxy      string `json:"proxy,omitempty"`       // proxy URL used instead of the HTTPS_PROXY environment variable
+	CABundle   string `json:"ca_bundle,omitempty"`   // PEM file of certificates trusted in addition to the system roots
+	ClientCert string `json:"client_cert,omitempty"` // PEM client certificate presented for mutual TLS
+	ClientKey  string `json:"client_key,omitempty"`  // PEM private key for client_cert
+}
+
+// BaseURL returns the OneLogin API base URL for the profile. An explicit api_url takes precedence
+// over the tenant subdomain, which takes precedence over the region.
+func (p Profile) BaseURL() string {
+	switch {
+	case p.APIURL != "":
+		return strings.TrimSuffix(p.APIURL, "/")
+	case p.Subdomain != "":
+		return fmt.Sprintf("https://%s.onelogin.com", p.Subdomain)
+	default:
+		return fmt.Sprintf("https://api.%s.onelogin.com", p.Region)
+	}
+}
+
+// Redacted returns a copy of the profile with its secrets masked, safe for printing
+func (p Profile) Redacted() Profile {
+	p.ClientSecret = Redact(p.ClientSecret)
+	if p.Okta != nil {
+		okta := *p.Okta
+		okta.APIToken = Redact(okta.APIToken)
+		p.Okta = &okta
+	}
+	if p.HTTP != nil {
+		http := *p.HTTP
+		http.Proxy = RedactURL(http.Proxy)
+		p.HTTP = &http
+	}
+	return p
+}
+
+// Redact masks a secret for display. Long secrets keep their last 4 characters so they can be told apart.
+func Redact(secret string) string {
+	switch {
+	case secret == "":
+		return ""
+	case len(secret) < 16:
+		return "********"
+	default:
+		return "********" + secret[len(secret)-4:]
+	}
+}
+
+// RedactURL masks any password in a URL, such as a proxy's, the way url.URL.Redacted does in newer Go releases
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+func (p Profile) hasCustomEndpoint() bool {
+	return p.APIURL != "" || p.Subdomain != ""
 }
 
 func (p ProfileService) GetActive() *Profile {
@@ -33,7 +126,21 @@ func (p ProfileService) GetActive() *Profile {
 	return nil
 }
 
+// Select returns the profile with the given name, or the active profile when no name is given.
+// Returns nil if no name is given and no profile is active.
+func (p ProfileService) Select(name string) *Profile {
+	if name == "" {
+		return p.GetActive()
+	}
+	profile := p.Find(name)
+	if profile == nil {
+		log.Fatalf("Profile %s does not exist!\n", name)
+	}
+	return profile
+}
+
 func (p ProfileService) Activate(name string) {
+	defer p.Repository.lock()()
 	profiles := p.Index()
 	for n, prof := range profiles {
 		if n == name {
@@ -62,53 +169,226 @@ func (p ProfileService) Index() map[string]*Profile {
 	if len(fileData) == 0 || fileData[0] == 0 { // no data in file
 		return existingProfiles
 	}
-	err = json.Unmarshal(bytes.Trim(fileData, "\x00"), &existingProfiles)
+	existingProfiles, err = unmarshalProfiles(bytes.Trim(fileData, "\x00"))
 	if err != nil {
 		log.Fatalln("Unable to parse profiles file!", err)
 	}
 	return existingProfiles
 }
 
+// Create prompts for and verifies the new profile before taking the profiles lock, so other commands aren't
+// held up while the user types, then saves it against the latest profiles
 func (p ProfileService) Create(name string) {
-	existingProfiles := p.Index()
-	profile := existingProfiles[name]
-	if profile != nil {
+	if p.Find(name) != nil {
 		log.Fatalln("Profile with this name already exists!")
 	}
-	profile = &Profile{Name: name}
-	if len(existingProfiles) == 0 {
-		profile.Active = true
+	profile := &Profile{Name: name}
+	p.fillProfile(profile)
+
+	defer p.Repository.lock()()
+	existingProfiles := p.Index()
+	if existingProfiles[name] != nil {
+		log.Fatalln("Profile with this name already exists!")
 	}
-	collectProfileInput(profile, p.InputReader)
-	existingProfiles[(*profile).Name] = profile
+	profile.Active = len(existingProfiles) == 0
+	existingProfiles[name] = profile
 	p.Repository.persist(existingProfiles)
 }
 
+// Update prompts for and verifies the changes before taking the profiles lock, then saves them against the
+// latest profiles. Whether the profile is active is taken from the latest profiles, as it isn't edited here.
 func (p ProfileService) Update(name string) {
-	existingProfiles := p.Index()
-	profile := existingProfiles[name]
+	profile := p.Find(name)
 	if profile == nil {
 		log.Fatalln("Profile does not exist!")
 	}
-	collectProfileInput(profile, p.InputReader)
-	existingProfiles[(*profile).Name] = profile
+	p.fillProfile(profile)
+
+	defer p.Repository.lock()()
+	existingProfiles := p.Index()
+	current := existingProfiles[name]
+	if current == nil {
+		log.Fatalln("Profile was removed while it was being updated!")
+	}
+	profile.Active = current.Active
+	existingProfiles[name] = profile
 	p.Repository.persist(existingProfiles)
 }
 
 func (p ProfileService) Remove(name string) {
+	defer p.Repository.lock()()
 	existingProfiles := p.Index()
 	delete(existingProfiles, name)
 	p.Repository.persist(existingProfiles)
 }
 
+// Encrypted reports whether the profiles are kept in an encrypted file
+func (p ProfileService) Encrypted() bool {
+	_, ok := p.Repository.(EncryptedFileRepository)
+	return ok
+}
+
+// MigrateTo copies every profile into the given Repository, e.g. to move a plaintext profiles file
+// into an EncryptedFileRepository backed by the same file
+func (p ProfileService) MigrateTo(repository Repository) {
+	defer p.Repository.lock()()
+	repository.persist(p.Index())
+}
+
+// fillProfile applies the preset values to the profile, then prompts for the rest or,
+// when NonInteractive is set, validates what was given. Finally the Verifier gets a say.
+func (p ProfileService) fillProfile(profile *Profile) {
+	profile.Apply(p.Preset)
+	if p.NonInteractive {
+		if err := validateProfile(*profile); err != nil {
+			log.Fatalln("Invalid profile:", err)
+		}
+	} else {
+		collectProfileInput(profile, p.InputReader)
+	}
+	if p.Verifier != nil {
+		if err := p.Verifier(*profile); err != nil {
+			log.Fatalln("Unable to verify profile credentials, profile not saved:", err)
+		}
+	}
+}
+
+// Apply copies every non-empty value of preset onto the profile. The name and active state are left alone.
+func (p *Profile) Apply(preset *Profile) {
+	if preset == nil {
+		return
+	}
+	if preset.Region != "" {
+		p.Region = preset.Region
+	}
+	if preset.ClientID != "" {
+		p.ClientID = preset.ClientID
+	}
+	if preset.ClientSecret != "" {
+		p.ClientSecret = preset.ClientSecret
+	}
+	if preset.CredentialHelper != "" {
+		// the helper supplies the secret, so it is no longer kept in the file
+		p.CredentialHelper = preset.CredentialHelper
+		p.ClientSecret = ""
+	}
+	if preset.APIURL != "" {
+		p.APIURL = preset.APIURL
+	}
+	if preset.Subdomain != "" {
+		p.Subdomain = preset.Subdomain
+	}
+	if preset.Okta != nil {
+		if p.Okta == nil {
+			p.Okta = &OktaSource{}
+		}
+		if preset.Okta.OrgName != "" {
+			p.Okta.OrgName = preset.Okta.OrgName
+		}
+		if preset.Okta.BaseURL != "" {
+			p.Okta.BaseURL = preset.Okta.BaseURL
+		}
+		if preset.Okta.APIToken != "" {
+			p.Okta.APIToken = preset.Okta.APIToken
+		}
+	}
+	if preset.AWS != nil {
+		if p.AWS == nil {
+			p.AWS = &AWSSource{}
+		}
+		if preset.AWS.Region != "" {
+			p.AWS.Region = preset.AWS.Region
+		}
+		if preset.AWS.Profile != "" {
+			p.AWS.Profile = preset.AWS.Profile
+		}
+	}
+	if preset.HTTP != nil {
+		if p.HTTP == nil {
+			p.HTTP = &HTTPSettings{}
+		}
+		if preset.HTTP.Timeout != 0 {
+			p.HTTP.Timeout = preset.HTTP.Timeout
+		}
+		if preset.HTTP.MaxRetries != nil {
+			p.HTTP.MaxRetries = preset.HTTP.MaxRetries
+		}
+		if preset.HTTP.Proxy != "" {
+			p.HTTP.Proxy = preset.HTTP.Proxy
+		}
+		if preset.HTTP.CABundle != "" {
+			p.HTTP.CABundle = preset.HTTP.CABundle
+		}
+		if preset.HTTP.ClientCert != "" {
+			p.HTTP.ClientCert = preset.HTTP.ClientCert
+		}
+		if preset.HTTP.ClientKey != "" {
+			p.HTTP.ClientKey = preset.HTTP.ClientKey
+		}
+	}
+}
+
+// validateProfile applies the same rules as the interactive prompts to a fully assembled profile
+func validateProfile(p Profile) error {
+	if p.APIURL != "" {
+		u, err := url.Parse(p.APIURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errors.New("api_url must be an absolute http(s) URL")
+		}
+	}
+	if p.Subdomain != "" && !subdomainRegex.MatchString(p.Subdomain) {
+		return errors.New("subdomain may only contain letters, digits and hyphens")
+	}
+	if !validRegion(p.Region) && !(p.Region == "" && p.hasCustomEndpoint()) {
+		return errors.New("region must be us or eu")
+	}
+	if p.HTTP != nil {
+		if p.HTTP.Timeout < 0 || (p.HTTP.MaxRetries != nil && *p.HTTP.MaxRetries < 0) {
+			return errors.New("http timeout and max_retries cannot be negative")
+		}
+		if p.HTTP.Proxy != "" {
+			if u, err := url.Parse(p.HTTP.Proxy); err != nil || u.Scheme == "" || u.Host == "" {
+				return errors.New("http proxy must be an absolute URL e.g. http://proxy.example.com:3128")
+			}
+		}
+		if (p.HTTP.ClientCert == "") != (p.HTTP.ClientKey == "") {
+			return errors.New("http client_cert and client_key must be given together")
+		}
+	}
+	if p.CredentialHelper != "" {
+		return nil
+	}
+	if strings.TrimSpace(p.ClientID) == "" {
+		return errors.New("client_id cannot be blank")
+	}
+	if strings.TrimSpace(p.ClientSecret) == "" {
+		return errors.New("client_secret cannot be blank")
+	}
+	return nil
+}
+
+func validRegion(region string) bool {
+	return region == "us" || region == "eu"
+}
+
+// readLine reads one line of user input. Running out of input before an answer was given is fatal
+// so scripts piping partial answers don't loop on the prompt forever.
+func readLine(reader *bufio.Reader) string {
+	userInput, err := reader.ReadString('\n')
+	if err != nil && len(userInput) == 0 {
+		log.Fatalln("Unexpected end of input!")
+	}
+	return userInput
+}
+
 func collectProfileInput(p *Profile, rdr io.Reader) {
 	var userInput string
 	reader := bufio.NewReader(rdr)
 	for {
 		fmt.Printf("Add the profile's REGION (us or eu) [Enter to accept %s]: \n", p.Region)
-		userInput, _ = reader.ReadString('\n')
+		userInput = readLine(reader)
 		userInput = strings.ToLower(strings.TrimSuffix(userInput, "\n"))
-		if userInput == "us" || userInput == "eu" || (len(userInput) == 0 && p.Region != "") {
+		if validRegion(userInput) || (len(userInput) == 0 && (p.Region != "" || p.hasCustomEndpoint())) {
 			if len(userInput) == 0 && p.Region != "" {
 				userInput = p.Region
 			}
@@ -118,9 +398,14 @@ func collectProfileInput(p *Profile, rdr io.Reader) {
 	}
 	p.Region = userInput
 
+	if p.CredentialHelper != "" {
+		fmt.Println("Client credentials will be supplied by credential helper", p.CredentialHelper)
+		return
+	}
+
 	fmt.Printf("Add the profile's CLIENT_ID [Enter to accept %s]: \n", p.ClientID)
 	for {
-		userInput, _ = reader.ReadString('\n')
+		userInput = readLine(reader)
 		if userInput == "\n" && p.ClientID != "" {
 			break
 		}
@@ -133,7 +418,7 @@ func collectProfileInput(p *Profile, rdr io.Reader) {
 
 	fmt.Printf("Add the profile's CLIENT_SECRET [Enter to accept %s]: \n", p.ClientSecret)
 	for {
-		userInput, _ = reader.ReadString('\n')
+		userInput = readLine(reader)
 		if userInput == "\n" && p.ClientSecret != "" {
 			break
 		}
@@ -0,0 +1,220 @@
+package tfimport
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	tfimportables "github.com/onelogin/onelogin/terraform/importables"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Config is what the existing Terraform configuration already declares, so the importer only adds what is new
+type Config struct {
+	Providers map[string]string // provider source, e.g. onelogin/onelogin, to the file requiring it
+	Resources map[string]string // resource address, e.g. onelogin_apps.my_app, to the file defining it. Module paths are dropped.
+	Imports   map[string]string // resource address targeted by an import block to the file it is in
+	ImportIDs map[string]string // resource type and id targeted by an import block, e.g. "onelogin_apps 1234", to the file it is in
+	// RequiredProviders is the root module file with a required_providers block, which new providers are added to
+	// as a module can only have one. Empty if there is none.
+	RequiredProviders string
+}
+
+func newConfig() *Config {
+	return &Config{Providers: map[string]string{}, Resources: map[string]string{}, Imports: map[string]string{}, ImportIDs: map[string]string{}}
+}
+
+// LoadConfig parses every .tf file in dir, and those of the local modules they call, except the ignored files
+func LoadConfig(dir string, ignore ...string) (*Config, error) {
+	config := newConfig()
+	ignored := map[string]bool{}
+	for _, path := range ignore {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		ignored[abs] = true
+	}
+	return config, config.loadDir(dir, true, map[string]bool{}, ignored)
+}
+
+// ParseConfig parses the contents of one configuration file
+func ParseConfig(src []byte, filename string) (*Config, error) {
+	config := newConfig()
+	return config, config.parse(src, filename, true, nil)
+}
+
+func (c *Config) loadDir(dir string, root bool, visited map[string]bool, ignored map[string]bool) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if visited[abs] {
+		return nil
+	}
+	visited[abs] = true
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	modules := []string{}
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil && ignored[abs] {
+			continue
+		}
+		// #nosec G304 reading the configuration in the working directory
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %s", path, err)
+		}
+		if err := c.parse(src, path, root, &modules); err != nil {
+			return err
+		}
+	}
+	for _, source := range modules {
+		if err := c.loadDir(filepath.Join(dir, source), false, visited, ignored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parse records the providers, resources and import blocks in src, and the local module sources it calls if modules is given.
+// root tells whether src is part of the root module.
+func (c *Config) parse(src []byte, filename string, root bool, modules *[]string) error {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return fmt.Errorf("unable to parse %s: %s", filename, diags.Error())
+	}
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		switch block.Type {
+		case "resource":
+			if len(block.Labels) != 2 {
+				continue
+			}
+			address := block.Labels[0] + "." + block.Labels[1]
+			// the root module is read first, so its resources win over ones of the same address in modules
+			if _, ok := c.Resources[address]; !ok {
+				c.Resources[address] = filename
+			}
+		case "terraform":
+			for _, nested := range block.Body.Blocks {
+				if nested.Type == "required_providers" {
+					c.parseRequiredProviders(nested.Body, filename)
+					if root && c.RequiredProviders == "" {
+						c.RequiredProviders = filename
+					}
+				}
+			}
+		case "import":
+			c.parseImport(block.Body, filename)
+		case "module":
+			source, ok := stringAttribute(block.Body, "source")
+			if ok && modules != nil && (strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")) {
+				*modules = append(*modules, source)
+			}
+		}
+	}
+	return nil
+}
+
+// parseRequiredProviders records each provider's source. Providers given only a version come from the hashicorp namespace.
+func (c *Config) parseRequiredProviders(body *hclsyntax.Body, filename string) {
+	for name, attribute := range body.Attributes {
+		value, diags := attribute.Expr.Value(nil)
+		if diags.HasErrors() {
+			continue
+		}
+		source := "hashicorp/" + name
+		if value.Type().IsObjectType() && value.Type().HasAttribute("source") {
+			if s := value.GetAttr("source"); s.Type() == cty.String && s.IsKnown() && !s.IsNull() {
+				source = s.AsString()
+			}
+		}
+		// sources may be given with the registry host, e.g. registry.terraform.io/onelogin/onelogin
+		if parts := strings.Split(source, "/"); len(parts) == 3 {
+			source = strings.Join(parts[1:], "/")
+		}
+		c.Providers[source] = filename
+	}
+}
+
+// parseImport records the resource an import block targets and the id it imports
+func (c *Config) parseImport(body *hclsyntax.Body, filename string) {
+	to, ok := body.Attributes["to"]
+	if !ok {
+		return
+	}
+	traversal, diags := hcl.AbsTraversalForExpr(to.Expr)
+	if diags.HasErrors() {
+		return
+	}
+	address := resourceAddress(traversal)
+	if address == "" {
+		return
+	}
+	c.Imports[address] = filename
+	if id, ok := stringAttribute(body, "id"); ok {
+		c.ImportIDs[strings.Split(address, ".")[0]+" "+id] = filename
+	}
+}
+
+// resourceAddress returns the type.name of the resource a traversal such as module.apps.onelogin_apps.my_app["x"] refers to
+func resourceAddress(traversal hcl.Traversal) string {
+	names := []string{}
+	for _, step := range traversal {
+		switch s := step.(type) {
+		case hcl.TraverseRoot:
+			names = append(names, s.Name)
+		case hcl.TraverseAttr:
+			names = append(names, s.Name)
+		}
+	}
+	for len(names) > 2 && names[0] == "module" {
+		names = names[2:]
+	}
+	if len(names) != 2 {
+		return ""
+	}
+	return names[0] + "." + names[1]
+}
+
+func stringAttribute(body *hclsyntax.Body, name string) (string, bool) {
+	attribute, ok := body.Attributes[name]
+	if !ok {
+		return "", false
+	}
+	value, diags := attribute.Expr.Value(nil)
+	if diags.HasErrors() || value.IsNull() || !value.IsKnown() || value.Type() != cty.String {
+		return "", false
+	}
+	return value.AsString(), true
+}
+
+// ProviderSources lists the provider sources the configuration requires
+func (c *Config) ProviderSources() []string {
+	sources := make([]string, 0, len(c.Providers))
+	for source := range c.Providers {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Defines reports whether the resource is defined or imported by the configuration, by address or by the id an import block targets
+func (c *Config) Defines(resourceDefinition tfimportables.ResourceDefinition) bool {
+	address := resourceDefinition.Type + "." + resourceDefinition.Name
+	if _, ok := c.Resources[address]; ok {
+		return true
+	}
+	if _, ok := c.Imports[address]; ok {
+		return true
+	}
+	_, ok := c.ImportIDs[resourceDefinition.Type+" "+resourceDefinition.ImportID]
+	return ok
+}
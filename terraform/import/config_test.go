@@ -0,0 +1,140 @@
+package tfimport
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tfimportables "github.com/onelogin/onelogin/terraform/importables"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := map[string]struct {
+		Input             string
+		ExpectedProviders []string
+		ExpectedDefined   []tfimportables.ResourceDefinition
+		ExpectedNew       []tfimportables.ResourceDefinition
+	}{
+		"It reads quoted and unquoted resource labels": {
+			Input: `
+				resource onelogin_apps unquoted {}
+				resource "onelogin_roles" "quoted" {
+				  name = "Admins"
+				}
+			`,
+			ExpectedProviders: []string{},
+			ExpectedDefined: []tfimportables.ResourceDefinition{
+				{Type: "onelogin_apps", Name: "unquoted"},
+				{Type: "onelogin_roles", Name: "quoted"},
+			},
+			ExpectedNew: []tfimportables.ResourceDefinition{
+				{Type: "onelogin_apps", Name: "quoted"},
+			},
+		},
+		"It reads provider sources, with or without the registry host": {
+			Input: `
+				terraform {
+				  required_providers {
+				    onelogin = {
+				      source  = "registry.terraform.io/onelogin/onelogin"
+				      version = "~> 0.1"
+				    }
+				    aws = "~> 3.0"
+				    okta = {
+				      source = "okta/okta"
+				    }
+				  }
+				}
+			`,
+			ExpectedProviders: []string{"hashicorp/aws", "okta/okta", "onelogin/onelogin"},
+		},
+		"It reads the resources and ids targeted by import blocks, including those in modules": {
+			Input: `
+				import {
+				  to = module.iam.module.roles.onelogin_roles.admins
+				  id = "1"
+				}
+				import {
+				  to = onelogin_users.jane["primary"]
+				  id = "2"
+				}
+			`,
+			ExpectedProviders: []string{},
+			ExpectedDefined: []tfimportables.ResourceDefinition{
+				{Type: "onelogin_roles", Name: "admins"},
+				{Type: "onelogin_users", Name: "jane"},
+				{Type: "onelogin_roles", Name: "renamed_admins", ImportID: "1"},
+			},
+			ExpectedNew: []tfimportables.ResourceDefinition{
+				{Type: "onelogin_users", Name: "john", ImportID: "1"},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(test.Input), "main.tf")
+			assert.Nil(t, err)
+			assert.Equal(t, test.ExpectedProviders, config.ProviderSources())
+			for _, resourceDefinition := range test.ExpectedDefined {
+				assert.True(t, config.Defines(resourceDefinition), resourceDefinition.Name)
+			}
+			for _, resourceDefinition := range test.ExpectedNew {
+				assert.False(t, config.Defines(resourceDefinition), resourceDefinition.Name)
+			}
+		})
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	_, err := ParseConfig([]byte("resource onelogin_apps {"), "main.tf")
+	assert.NotNil(t, err)
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfimport")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"main.tf":            "module \"apps\" {\n  source = \"./modules/apps\"\n}\nmodule \"remote\" {\n  source = \"onelogin/apps/onelogin\"\n}\n",
+		"roles.tf":           "resource \"onelogin_roles\" \"admins\" {}\n",
+		"versions.tf":        "terraform {\n  required_providers {\n    onelogin = {\n      source = \"onelogin/onelogin\"\n    }\n  }\n}\n",
+		"modules/apps/a.tf":  "resource \"onelogin_apps\" \"portal\" {}\nmodule \"self\" {\n  source = \"../apps\"\n}\n",
+		"modules/apps/notes": "resource \"onelogin_apps\" \"ignored\" {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+
+	config, err := LoadConfig(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"onelogin/onelogin"}, config.ProviderSources())
+	assert.Equal(t, map[string]string{
+		"onelogin_roles.admins": filepath.Join(dir, "roles.tf"),
+		"onelogin_apps.portal":  filepath.Join(dir, "modules/apps/a.tf"),
+	}, config.Resources)
+	assert.Equal(t, filepath.Join(dir, "versions.tf"), config.RequiredProviders)
+}
+
+func TestLoadConfigRequiredProviders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfimport")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"main.tf":                  "module \"apps\" {\n  source = \"./modules/apps\"\n}\n",
+		"modules/apps/versions.tf": "terraform {\n  required_providers {\n    onelogin = {\n      source = \"onelogin/onelogin\"\n    }\n  }\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+
+	config, err := LoadConfig(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"onelogin/onelogin"}, config.ProviderSources())
+	assert.Equal(t, "", config.RequiredProviders, "a module's required_providers block is its own, not the root module's")
+}
@@ -1,95 +1,173 @@
 package tfimport
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"io"
-	"regexp"
+	"io/ioutil"
+	"sort"
 	"strings"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 	tfimportables "github.com/onelogin/onelogin/terraform/importables"
+	"github.com/zclconf/go-cty/cty"
 )
 
-var providerRegex *regexp.Regexp = regexp.MustCompile(`^\s*?source\s?=\s?"[a-zA-Z]+\/[a-zA-Z]+"?`)
-var resourceRegex *regexp.Regexp = regexp.MustCompile(`(\w*resource\w*)\s([a-zA-Z\_\-]*)\s([a-zA-Z0-9\_\-]*[0-9]*)\s?\{`)
+// DetermineNewResourcesAndProviders checks resource list from remote and cross references the existing configuration to see which new resources and providers need to be added and imported
+func DetermineNewResourcesAndProviders(config *Config, resourcesFromRemote []tfimportables.ResourceDefinition) ([]tfimportables.ResourceDefinition, []string) {
+	providers := map[string]bool{}
+	seen := map[string]bool{} // importables that overlap may both yield a resource
+	resourceDefinitionsToImport := []tfimportables.ResourceDefinition{}
+	for _, resourceDefinition := range resourcesFromRemote {
+		resourceKey := fmt.Sprintf("%s.%s", resourceDefinition.Type, resourceDefinition.Name)
+		if !config.Defines(resourceDefinition) && !seen[resourceKey] {
+			resourceDefinitionsToImport = append(resourceDefinitionsToImport, resourceDefinition)
+			if _, ok := config.Providers[resourceDefinition.Provider]; !ok {
+				providers[resourceDefinition.Provider] = true
+			}
+		}
+		seen[resourceKey] = true
+	}
 
-func providerFromRegexMatches(matches []string) string {
-	return strings.ReplaceAll(strings.ReplaceAll(strings.Split(matches[0], "=")[1], "\"", ""), " ", "")
+	providerDefinitions := []string{}
+	for providerName := range providers {
+		providerDefinitions = append(providerDefinitions, providerName)
+	}
+	sort.Strings(providerDefinitions)
+	return resourceDefinitionsToImport, providerDefinitions
 }
 
-func resourceFromRegexMatches(matches []string) string {
-	return fmt.Sprintf("%s.%s", matches[len(matches)-2], matches[len(matches)-1])
+// AddNewProvidersAndResourceHCL appends empty resource definitions to the existing .tf file so terraform import will pick them up
+func AddNewProvidersAndResourceHCL(planFile io.Reader, newResourceDefinitions []tfimportables.ResourceDefinition, newProviderDefinitions []string) (string, error) {
+	existing, err := ioutil.ReadAll(planFile)
+	if err != nil {
+		return "", err
+	}
+	var stubs strings.Builder
+	for i, resourceDefinition := range newResourceDefinitions {
+		if i > 0 {
+			stubs.WriteString("\n")
+		}
+		stubs.WriteString(fmt.Sprintf("resource %q %q {}\n", resourceDefinition.Type, resourceDefinition.Name))
+	}
+	return MergeHCL(existing, newProviderDefinitions, []byte(stubs.String())), nil
 }
 
-// DetermineNewResourcesAndProviders checks resource list from remote and cross references existing tf plan file to see which new resources and providers need to be added and imported
-func DetermineNewResourcesAndProviders(planFile io.Reader, resourcesFromRemote []tfimportables.ResourceDefinition) ([]tfimportables.ResourceDefinition, []string) {
-	// running tab of provider and resource definitions in HCL file
-	definitionHeaderCounter := map[string]map[string]int{"provider": {}, "resource": {}}
+// MergeHCL appends the new resources' HCL to the existing configuration and declares each new provider, in its
+// required_providers block if it has one or else in a new one. The existing content, comments included, is otherwise
+// kept byte for byte.
+func MergeHCL(existing []byte, newProviderDefinitions []string, resourceHCL []byte) string {
+	var builder strings.Builder
+	if len(newProviderDefinitions) > 0 {
+		if updated, ok := AddRequiredProviders(existing, newProviderDefinitions); ok {
+			existing, newProviderDefinitions = updated, nil
+		}
+	}
+	builder.Write(existing)
+	if len(newProviderDefinitions) > 0 {
+		providersFile := hclwrite.NewEmptyFile()
+		appendRequiredProviders(providersFile.Body(), newProviderDefinitions)
+		appendSection(&builder, hclwrite.Format(providersFile.Bytes()))
+	}
+	appendSection(&builder, bytes.TrimLeft(resourceHCL, "\n"))
+	return builder.String()
+}
 
-	scanner := bufio.NewScanner(planFile)
-	for scanner.Scan() {
-		line := scanner.Text()
-		providerLineMatches := providerRegex.FindStringSubmatch(line)
-		resourceLineMatches := resourceRegex.FindStringSubmatch(line)
-		if len(providerLineMatches) > 0 {
-			definitionKey := providerFromRegexMatches(providerLineMatches)
-			definitionHeaderCounter["provider"][definitionKey]++
+// AddRequiredProviders adds an entry for each provider to the first required_providers block in src, keeping the rest
+// of src byte for byte. It reports false, and leaves src alone, if src has no such block or can't be parsed.
+func AddRequiredProviders(src []byte, providers []string) ([]byte, bool) {
+	file, diags := hclsyntax.ParseConfig(src, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return src, false
+	}
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "terraform" {
+			continue
 		}
-		if len(resourceLineMatches) > 0 {
-			definitionKey := resourceFromRegexMatches(resourceLineMatches)
-			definitionHeaderCounter["resource"][definitionKey]++
+		for _, nested := range block.Body.Blocks {
+			if nested.Type == "required_providers" {
+				return insertRequiredProviders(src, nested, providers), true
+			}
 		}
 	}
+	return src, false
+}
 
-	// loop over all resources from remote and any that we dont see in the plan file should be tallied and added as a definition to import
-	resourceDefinitionsToImport := []tfimportables.ResourceDefinition{}
-	for _, resourceDefinition := range resourcesFromRemote {
-		if definitionHeaderCounter["provider"][resourceDefinition.Provider] == 0 {
-			definitionHeaderCounter["provider"][resourceDefinition.Provider]++
-		}
-		if definitionHeaderCounter["resource"][fmt.Sprintf("%s.%s", resourceDefinition.Type, resourceDefinition.Name)] == 0 {
-			resourceDefinitionsToImport = append(resourceDefinitionsToImport, resourceDefinition)
+// insertRequiredProviders writes the providers' entries before the closing brace of the required_providers block,
+// indented one level deeper than it
+func insertRequiredProviders(src []byte, block *hclsyntax.Block, providers []string) []byte {
+	indent := strings.Repeat(" ", block.TypeRange.Start.Column-1)
+	entriesFile := hclwrite.NewEmptyFile()
+	for _, provider := range providers {
+		p := strings.Split(provider, "/")[1]
+		entriesFile.Body().SetAttributeValue(p, cty.ObjectVal(map[string]cty.Value{"source": cty.StringVal(provider)}))
+	}
+	var entries strings.Builder
+	for _, line := range strings.SplitAfter(string(hclwrite.Format(entriesFile.Bytes())), "\n") {
+		if line != "" {
+			entries.WriteString(indent + "  " + line)
 		}
 	}
 
-	providerDefinitions := []string{}
-	for providerName := range definitionHeaderCounter["provider"] {
-		providerDefinitions = append(providerDefinitions, providerName)
+	closing := block.CloseBraceRange.Start.Byte
+	lineStart := bytes.LastIndexByte(src[:closing], '\n') + 1
+	var out bytes.Buffer
+	if len(bytes.TrimSpace(src[lineStart:closing])) == 0 {
+		// the closing brace is on its own line, so the entries go on the lines before it
+		out.Write(src[:lineStart])
+		out.WriteString(entries.String())
+		out.Write(src[lineStart:])
+	} else {
+		out.Write(src[:closing])
+		out.WriteString("\n" + entries.String() + indent)
+		out.Write(src[closing:])
 	}
-	return resourceDefinitionsToImport, providerDefinitions
+	return out.Bytes()
 }
 
-// AddNewProvidersAndResourceHCL appends empty resource definitions to the existing main.tf file so terraform import will pick them up
-func AddNewProvidersAndResourceHCL(planFile io.Reader, newResourceDefinitions []tfimportables.ResourceDefinition, newProviderDefinitions []string) string {
-	var builder strings.Builder // builder represents the new state of our .tf file copied over from the existing tf file
-
-	// we'll add the provider source headers for any new providers we dont know about
-	builder.WriteString(fmt.Sprintf("terraform {\n\trequired_providers {\n"))
-	for _, newProvider := range newProviderDefinitions {
-		p := strings.Split(newProvider, "/")[1]
-		builder.WriteString(fmt.Sprintf("\t\t%s = {\n\t\t\tsource = \"%s\"\n\t\t}\n", p, newProvider))
+// appendSection writes the section after what is already in the builder, separated from it by one blank line
+func appendSection(builder *strings.Builder, section []byte) {
+	if len(section) == 0 {
+		return
 	}
-	builder.WriteString(fmt.Sprintf("\t}\n}\n"))
-
-	// then we scan the existing .tf file reading in all the existing lines to our new copy (the string builder)
-	scanner := bufio.NewScanner(planFile)
-	shouldRead := false
-	for scanner.Scan() {
-		t := scanner.Text()
-		m := resourceRegex.FindStringSubmatch(t)
-		if len(m) != 0 {
-			shouldRead = true
-		}
-		if shouldRead {
-			builder.WriteString(fmt.Sprintf("%s\n", t))
+	if written := builder.String(); len(written) > 0 {
+		switch {
+		case strings.HasSuffix(written, "\n\n"):
+		case strings.HasSuffix(written, "\n"):
+			builder.WriteString("\n")
+		default:
+			builder.WriteString("\n\n")
 		}
 	}
+	builder.Write(section)
+}
 
-	// finally we add the resource xxx xxx {} lines for the resources we want to import
-	for _, resourceDefinition := range newResourceDefinitions {
-		builder.WriteString(fmt.Sprintf("resource %s %s {}\n", resourceDefinition.Type, resourceDefinition.Name))
+// appendRequiredProviders appends a terraform block declaring the providers to body
+func appendRequiredProviders(body *hclwrite.Body, providers []string) {
+	providersBody := body.AppendNewBlock("terraform", nil).Body().AppendNewBlock("required_providers", nil).Body()
+	for _, provider := range providers {
+		p := strings.Split(provider, "/")[1]
+		providersBody.SetAttributeValue(p, cty.ObjectVal(map[string]cty.Value{"source": cty.StringVal(provider)}))
 	}
+}
 
-	// this is the representation of the new state of the .tf file with empty resource headers ready for import
-	return builder.String()
+// ImportBlocksHCL returns Terraform 1.5+ import blocks for the resources so terraform plan can import them.
+// Their providers are declared with MergeHCL, so they join any required_providers block already in the file.
+func ImportBlocksHCL(newResourceDefinitions []tfimportables.ResourceDefinition) string {
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+	for _, resourceDefinition := range newResourceDefinitions {
+		if len(body.Blocks()) > 0 {
+			body.AppendNewline()
+		}
+		importBody := body.AppendNewBlock("import", nil).Body()
+		importBody.SetAttributeTraversal("to", hcl.Traversal{
+			hcl.TraverseRoot{Name: resourceDefinition.Type},
+			hcl.TraverseAttr{Name: resourceDefinition.Name},
+		})
+		importBody.SetAttributeValue("id", cty.StringVal(resourceDefinition.ImportID))
+	}
+	return string(hclwrite.Format(file.Bytes()))
 }
@@ -2,7 +2,6 @@ package tfimport
 
 import (
 	"io"
-	"strings"
 	"testing"
 
 	tfimportables "github.com/onelogin/onelogin/terraform/importables"
@@ -19,21 +18,20 @@ func (m *MockFile) Write(p []byte) (int, error) {
 }
 
 func (m *MockFile) Read(p []byte) (int, error) {
-	for i, b := range m.Content {
-		p[i] = b
-	}
-	return len(p), io.EOF
+	n := copy(p, m.Content)
+	m.Content = m.Content[n:]
+	return n, io.EOF
 }
 
 func TestFilterExistingDefinitions(t *testing.T) {
 	tests := map[string]struct {
-		InputReadWriter             io.Reader
+		InputConfig                 string
 		IncomingResourceDefinitions []tfimportables.ResourceDefinition
 		ExpectedResourceDefinitions []tfimportables.ResourceDefinition
 		ExpectedProviders           []string
 	}{
-		"it yields lists of resource definitions and providers not already defined in main.tf": {
-			InputReadWriter: strings.NewReader(`
+		"it yields lists of resource definitions and providers not already defined in the configuration": {
+			InputConfig: `
 				terraform {
 				  required_providers {
 				    onelogin = {
@@ -50,7 +48,7 @@ func TestFilterExistingDefinitions(t *testing.T) {
 				resource okra_saml_apps test_defined_already {
 					name = test_defined_already
 				}
-			`),
+			`,
 			IncomingResourceDefinitions: []tfimportables.ResourceDefinition{
 				{Provider: "onelogin/onelogin", Name: "defined_in_main_already", Type: "onelogin_apps"},
 				{Provider: "okra/okra", Name: "test_defined_already", Type: "okra_saml_apps"},
@@ -65,14 +63,69 @@ func TestFilterExistingDefinitions(t *testing.T) {
 				{Provider: "okra/okra", Name: "test", Type: "okra_saml_apps"},
 				{Provider: "aws/aws", Name: "test", Type: "aws_apps"},
 			},
-			ExpectedProviders: []string{"onelogin/onelogin", "okra/okra", "aws/aws"},
+			ExpectedProviders: []string{"aws/aws"},
+		},
+		"it yields resources collected by more than one importable once": {
+			InputConfig: "",
+			IncomingResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Provider: "onelogin/onelogin", Name: "test", Type: "onelogin_saml_apps"},
+				{Provider: "onelogin/onelogin", Name: "test", Type: "onelogin_roles"},
+				{Provider: "onelogin/onelogin", Name: "test", Type: "onelogin_saml_apps"},
+			},
+			ExpectedResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Provider: "onelogin/onelogin", Name: "test", Type: "onelogin_saml_apps"},
+				{Provider: "onelogin/onelogin", Name: "test", Type: "onelogin_roles"},
+			},
+			ExpectedProviders: []string{"onelogin/onelogin"},
+		},
+		"it reads resources and providers written by terraform fmt": {
+			InputConfig: `
+terraform {
+  required_providers {
+    onelogin = {
+      source  = "onelogin/onelogin"
+      version = "~> 0.1"
+    }
+  }
+}
+
+resource "onelogin_roles" "admins-1" {
+  name = "Admins"
+}
+`,
+			IncomingResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Provider: "onelogin/onelogin", Name: "admins-1", Type: "onelogin_roles"},
+				{Provider: "onelogin/onelogin", Name: "users-2", Type: "onelogin_roles"},
+			},
+			ExpectedResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Provider: "onelogin/onelogin", Name: "users-2", Type: "onelogin_roles"},
+			},
+			ExpectedProviders: []string{},
+		},
+		"it treats resources targeted by import blocks as defined": {
+			InputConfig: `
+				import {
+					to = onelogin_roles.admins
+					id = "1"
+				}
+			`,
+			IncomingResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Provider: "onelogin/onelogin", Name: "admins", Type: "onelogin_roles"},
+				{Provider: "onelogin/onelogin", Name: "users", Type: "onelogin_roles"},
+			},
+			ExpectedResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Provider: "onelogin/onelogin", Name: "users", Type: "onelogin_roles"},
+			},
+			ExpectedProviders: []string{"onelogin/onelogin"},
 		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			actualResourceDefinitions, actualProviderDefinitions := DetermineNewResourcesAndProviders(test.InputReadWriter, test.IncomingResourceDefinitions)
+			config, err := ParseConfig([]byte(test.InputConfig), "main.tf")
+			assert.Nil(t, err)
+			actualResourceDefinitions, actualProviderDefinitions := DetermineNewResourcesAndProviders(config, test.IncomingResourceDefinitions)
 			assert.Equal(t, test.ExpectedResourceDefinitions, actualResourceDefinitions)
-			assert.Equal(t, len(test.ExpectedProviders), len(actualProviderDefinitions))
+			assert.Equal(t, test.ExpectedProviders, actualProviderDefinitions)
 		})
 	}
 }
@@ -91,13 +144,156 @@ func TestAddNewProvidersAndResourceHCL(t *testing.T) {
 			},
 			TestFile:                 MockFile{},
 			InputProviderDefinitions: []string{"test/test", "test2/test2"},
-			ExpectedOut:              "terraform {\n\trequired_providers {\n\t\ttest = {\n\t\t\tsource = \"test/test\"\n\t\t}\n\t\ttest2 = {\n\t\t\tsource = \"test2/test2\"\n\t\t}\n\t}\n}\nresource test test {}\nresource test test {}\n",
+			ExpectedOut:              "terraform {\n  required_providers {\n    test = {\n      source = \"test/test\"\n    }\n    test2 = {\n      source = \"test2/test2\"\n    }\n  }\n}\n\nresource \"test\" \"test\" {}\n\nresource \"test\" \"test\" {}\n",
+		},
+		"it keeps the existing content as it is": {
+			InputResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Name: "new", Type: "onelogin_roles", ImportID: "2", Provider: "onelogin/onelogin"},
+			},
+			TestFile: MockFile{Content: []byte("# managed by hand\nlocals {\n\tprefix = \"ol\"\n}\nresource onelogin_roles old {\n  name = \"Old\" # keep\n  lifecycle {\n    ignore_changes = [users]\n  }\n}")},
+			ExpectedOut: "# managed by hand\nlocals {\n\tprefix = \"ol\"\n}\nresource onelogin_roles old {\n  name = \"Old\" # keep\n  lifecycle {\n    ignore_changes = [users]\n  }\n}" +
+				"\n\nresource \"onelogin_roles\" \"new\" {}\n",
 		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			actual := AddNewProvidersAndResourceHCL(&test.TestFile, test.InputResourceDefinitions, test.InputProviderDefinitions)
+			actual, err := AddNewProvidersAndResourceHCL(&test.TestFile, test.InputResourceDefinitions, test.InputProviderDefinitions)
+			assert.Nil(t, err)
 			assert.Equal(t, test.ExpectedOut, actual)
 		})
 	}
 }
+
+func TestMergeHCL(t *testing.T) {
+	tests := map[string]struct {
+		Existing     string
+		NewProviders []string
+		ResourceHCL  string
+		ExpectedOut  string
+	}{
+		"it writes only the resources to an empty file": {
+			ResourceHCL: "\nresource \"onelogin_roles\" \"admins\" {\n  name = \"Admins\"\n}\n",
+			ExpectedOut: "resource \"onelogin_roles\" \"admins\" {\n  name = \"Admins\"\n}\n",
+		},
+		"it separates each section from the existing content by one blank line": {
+			Existing:     "# roles\n\n",
+			NewProviders: []string{"onelogin/onelogin"},
+			ResourceHCL:  "resource \"onelogin_roles\" \"admins\" {}\n",
+			ExpectedOut:  "# roles\n\nterraform {\n  required_providers {\n    onelogin = {\n      source = \"onelogin/onelogin\"\n    }\n  }\n}\n\nresource \"onelogin_roles\" \"admins\" {}\n",
+		},
+		"it leaves the existing content alone when there is nothing to add": {
+			Existing:    "variable \"x\" {}",
+			ExpectedOut: "variable \"x\" {}",
+		},
+		"it adds new providers to the existing required_providers block": {
+			Existing: `terraform {
+  required_version = ">= 1.0"
+  required_providers {
+    # pinned for the 1.x API
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 3.0"
+    }
+  }
+}
+
+resource "aws_iam_user" "jane" {}
+`,
+			NewProviders: []string{"onelogin/onelogin"},
+			ResourceHCL:  "resource \"onelogin_roles\" \"admins\" {}\n",
+			ExpectedOut: `terraform {
+  required_version = ">= 1.0"
+  required_providers {
+    # pinned for the 1.x API
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 3.0"
+    }
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "aws_iam_user" "jane" {}
+
+resource "onelogin_roles" "admins" {}
+`,
+		},
+		"it adds new providers to an empty required_providers block on one line": {
+			Existing:     "terraform {\n  required_providers {}\n}\n",
+			NewProviders: []string{"okta/okta", "onelogin/onelogin"},
+			ExpectedOut:  "terraform {\n  required_providers {\n    okta = {\n      source = \"okta/okta\"\n    }\n    onelogin = {\n      source = \"onelogin/onelogin\"\n    }\n  }\n}\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.ExpectedOut, MergeHCL([]byte(test.Existing), test.NewProviders, []byte(test.ResourceHCL)))
+		})
+	}
+}
+
+func TestImportBlocksHCL(t *testing.T) {
+	tests := map[string]struct {
+		InputResourceDefinitions []tfimportables.ResourceDefinition
+		Existing                 string
+		NewProviders             []string
+		ExpectedOut              string
+	}{
+		"it writes an import block for each resource": {
+			InputResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Name: "admins", Type: "onelogin_roles", ImportID: "1", Provider: "onelogin/onelogin"},
+				{Name: "jane", Type: "aws_iam_user", ImportID: "jane", Provider: "hashicorp/aws"},
+			},
+			ExpectedOut: `import {
+  to = onelogin_roles.admins
+  id = "1"
+}
+
+import {
+  to = aws_iam_user.jane
+  id = "jane"
+}
+`,
+		},
+		"it declares new providers in the file's required_providers block when merged": {
+			InputResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Name: "admins", Type: "onelogin_roles", ImportID: "1", Provider: "onelogin/onelogin"},
+			},
+			Existing:     "terraform {\n  required_providers {\n    aws = {\n      source = \"hashicorp/aws\"\n    }\n  }\n}\n",
+			NewProviders: []string{"onelogin/onelogin"},
+			ExpectedOut: `terraform {
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+import {
+  to = onelogin_roles.admins
+  id = "1"
+}
+`,
+		},
+		"it escapes ids so they are read literally": {
+			InputResourceDefinitions: []tfimportables.ResourceDefinition{
+				{Name: "odd", Type: "test", ImportID: "a\"${b}%{c}\x00", Provider: "test/test"},
+			},
+			ExpectedOut: `import {
+  to = test.odd
+  id = "a\"$${b}%%{c}\u0000"
+}
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			importHCL := ImportBlocksHCL(test.InputResourceDefinitions)
+			assert.Equal(t, test.ExpectedOut, MergeHCL([]byte(test.Existing), test.NewProviders, []byte(importHCL)))
+		})
+	}
+}
@@ -1,10 +1,29 @@
 package tfimportables
 
 import (
+	"fmt"
+
 	"github.com/aws/aws-sdk-go/service/iam"
-	"log"
+	"github.com/onelogin/onelogin/clients"
 )
 
+func init() {
+	Register(Registration{
+		Name:        "aws_iam_user",
+		Aliases:     []string{"aws_iam_users"},
+		Types:       []string{"aws_iam_user"},
+		Client:      ClientAWS,
+		Description: "aws users",
+		New: func(clientList *clients.Clients) (Importable, error) {
+			awsClient, err := clientList.LoadAwsIamClient()
+			if err != nil {
+				return nil, err
+			}
+			return &AWSUsersImportable{Service: awsClient}, nil
+		},
+	})
+}
+
 type AWSUserQuerier interface {
 	ListUsers(input *iam.ListUsersInput) (*iam.ListUsersOutput, error)
 }
@@ -15,10 +34,10 @@ type AWSUsersImportable struct {
 
 // Interface requirement to be an Importable. Calls out to remote (aws api) and
 // creates their Terraform ResourceDefinitions
-func (i AWSUsersImportable) ImportFromRemote(searchId *string) []ResourceDefinition {
+func (i AWSUsersImportable) ImportFromRemote(searchId *string) ([]ResourceDefinition, error) {
 	usrs, err := i.Service.ListUsers(&iam.ListUsersInput{})
 	if err != nil {
-		log.Fatalln("There was a problem getting users", err)
+		return nil, fmt.Errorf("there was a problem getting users: %s", err)
 	}
 	out := make([]ResourceDefinition, len(usrs.Users))
 	for i, u := range usrs.Users {
@@ -29,7 +48,7 @@ func (i AWSUsersImportable) ImportFromRemote(searchId *string) []ResourceDefinit
 			ImportID: *u.UserName,
 		}
 	}
-	return out
+	return out, nil
 }
 
 func (i AWSUsersImportable) HCLShape() interface{} {
@@ -42,6 +42,18 @@ func TestGetImportable(t *testing.T) {
 				assert.Equal(t, test.Importables.importables[name], importable)
 				assert.Equal(t, test.Importables.importables[name], memoizedImportable)
 			}
+			_, err := test.Importables.Importable("onelogin_widgets")
+			assert.Error(t, err)
 		})
 	}
 }
+
+func TestImportableClientError(t *testing.T) {
+	importables := New(&clients.Clients{
+		ClientConfigs: clients.ClientConfigs{ReplayDir: "does-not-exist"},
+	})
+	importable, err := importables.Importable("onelogin_roles")
+	assert.Error(t, err)
+	assert.Nil(t, importable)
+	assert.Nil(t, importables.importables["onelogin_roles"], "a failed importable should not be memoized")
+}
@@ -1,8 +1,8 @@
 package tfimportables
 
 type Importable interface {
-	ImportFromRemote(searchId *string) []ResourceDefinition // transforms resources from remote to an array ResourceDefinitions to be inserted into an HCL file
-	HCLShape() interface{}                                  // dictates what fields on tfstate should be represented in HCL files
+	ImportFromRemote(searchId *string) ([]ResourceDefinition, error) // transforms resources from remote to an array ResourceDefinitions to be inserted into an HCL file
+	HCLShape() interface{}                                           // dictates what fields on tfstate should be represented in HCL files
 }
 
 // ResourceDefinition represents basic information about the resource to be imported
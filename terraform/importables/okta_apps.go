@@ -6,9 +6,27 @@ import (
 	"github.com/okta/okta-sdk-golang/v2/okta"
 	"github.com/okta/okta-sdk-golang/v2/okta/query"
 	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
+	"github.com/onelogin/onelogin/clients"
 	"time"
 )
 
+func init() {
+	Register(Registration{
+		Name:        "okta_apps",
+		Aliases:     []string{"okta_app_oauth", "okta_app_saml", "okta_app_basic_auth"},
+		Types:       []string{"okta_app_oauth", "okta_app_saml", "okta_app_basic_auth"},
+		Client:      ClientOkta,
+		Description: "okta apps",
+		New: func(clientList *clients.Clients) (Importable, error) {
+			oktaClient, err := clientList.LoadOktaClient()
+			if err != nil {
+				return nil, err
+			}
+			return &OktaAppsImportable{Service: oktaClient.Application}, nil
+		},
+	})
+}
+
 type OktaAppQuerier interface {
 	ListApplications(context.Context, *query.Params) ([]okta.App, *okta.Response, error)
 }
@@ -17,10 +35,13 @@ type OktaAppsImportable struct {
 	Service OktaAppQuerier
 }
 
-func (i OktaAppsImportable) ImportFromRemote(searchId *string) []ResourceDefinition {
-	apps := i.getAllApps()
+func (i OktaAppsImportable) ImportFromRemote(searchId *string) ([]ResourceDefinition, error) {
+	apps, err := i.getAllApps()
+	if err != nil {
+		return nil, err
+	}
 	rd := assembleOktaResourceDefinitions(apps)
-	return rd
+	return rd, nil
 }
 
 func assembleOktaResourceDefinitions(allApps []okta.App) []ResourceDefinition {
@@ -44,13 +65,12 @@ func assembleOktaResourceDefinitions(allApps []okta.App) []ResourceDefinition {
 	return resourceDefinitions
 }
 
-func (i OktaAppsImportable) getAllApps() []okta.App {
-	apps, resp, err := i.Service.ListApplications(context.TODO(), nil)
+func (i OktaAppsImportable) getAllApps() ([]okta.App, error) {
+	apps, _, err := i.Service.ListApplications(context.TODO(), nil)
 	if err != nil {
-		fmt.Println("ERROR", err)
-		fmt.Println("RESP", resp)
+		return nil, fmt.Errorf("error retrieving apps: %s", err)
 	}
-	return apps
+	return apps, nil
 }
 
 func (i OktaAppsImportable) HCLShape() interface{} {
@@ -63,7 +83,7 @@ type OktaAppData struct {
 	Links         interface{}                    `json:"_links,omitempty"`
 	Accessibility *okta.ApplicationAccessibility `json:"accessibility,omitempty"`
 	Created       *time.Time                     `json:"created,omitempty"`
-	Credentials   *okta.ApplicationCredentials   `json:"credentials,omitempty"`
+	Credentials   *OktaAppCredentials            `json:"credentials,omitempty"`
 	Features      []string                       `json:"features,omitempty"`
 	Id            string                         `json:"id,omitempty"`
 	Label         string                         `json:"label,omitempty"`
@@ -76,3 +96,25 @@ type OktaAppData struct {
 	Status        string                         `json:"status,omitempty"`
 	Visibility    *okta.ApplicationVisibility    `json:"visibility,omitempty"`
 }
+
+// OktaAppCredentials covers the credentials of basic auth, OIDC and SWA apps, so that only their secrets are hidden
+type OktaAppCredentials struct {
+	OauthClient      *OktaAppOAuthClient                          `json:"oauthClient,omitempty"`
+	Password         *OktaAppPassword                             `json:"password,omitempty"`
+	RevealPassword   *bool                                        `json:"revealPassword,omitempty"`
+	Scheme           string                                       `json:"scheme,omitempty"`
+	Signing          *okta.ApplicationCredentialsSigning          `json:"signing,omitempty"`
+	UserName         string                                       `json:"userName,omitempty"`
+	UserNameTemplate *okta.ApplicationCredentialsUsernameTemplate `json:"userNameTemplate,omitempty"`
+}
+
+type OktaAppOAuthClient struct {
+	AutoKeyRotation         *bool  `json:"autoKeyRotation,omitempty"`
+	ClientId                string `json:"client_id,omitempty"`
+	ClientSecret            string `json:"client_secret,omitempty" sensitive:"true"`
+	TokenEndpointAuthMethod string `json:"token_endpoint_auth_method,omitempty"`
+}
+
+type OktaAppPassword struct {
+	Value string `json:"value,omitempty" sensitive:"true"`
+}
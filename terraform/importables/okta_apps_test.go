@@ -62,7 +62,8 @@ func TestImportOktaAppFromRemote(t *testing.T) {
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			actual := test.Importable.ImportFromRemote(nil)
+			actual, err := test.Importable.ImportFromRemote(nil)
+			assert.Nil(t, err)
 			assert.Equal(t, test.Expected, actual)
 		})
 	}
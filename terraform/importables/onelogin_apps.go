@@ -2,13 +2,42 @@ package tfimportables
 
 import (
 	"fmt"
-	"log"
 	"strconv"
 
 	"github.com/onelogin/onelogin-go-sdk/pkg/services/apps"
 	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
+
+	"github.com/onelogin/onelogin/clients"
 )
 
+func init() {
+	for _, app := range []struct{ appType, description string }{
+		{"onelogin_apps", "onelogin all apps"},
+		{"onelogin_saml_apps", "onelogin SAML apps only"},
+		{"onelogin_oidc_apps", "onelogin OIDC apps only"},
+	} {
+		appType := app.appType
+		types, subsetOf := []string{appType}, "onelogin_apps"
+		if appType == "onelogin_apps" {
+			types, subsetOf = []string{"onelogin_apps", "onelogin_saml_apps", "onelogin_oidc_apps"}, ""
+		}
+		Register(Registration{
+			Name:        appType,
+			Types:       types,
+			SubsetOf:    subsetOf,
+			Client:      ClientOneLogin,
+			Description: app.description,
+			New: func(clientList *clients.Clients) (Importable, error) {
+				oneloginClient, err := clientList.LoadOneLoginClient()
+				if err != nil {
+					return nil, err
+				}
+				return &OneloginAppsImportable{Service: oneloginClient.Services.AppsV2, AppType: appType}, nil
+			},
+		})
+	}
+}
+
 type AppQuerier interface {
 	Query(query *apps.AppsQuery) ([]apps.App, error)
 	GetOne(id int32) (*apps.App, error)
@@ -21,25 +50,28 @@ type OneloginAppsImportable struct {
 
 // Interface requirement to be an Importable. Calls out to remote (onelogin api) and
 // creates their Terraform ResourceDefinitions
-func (i OneloginAppsImportable) ImportFromRemote(searchId *string) []ResourceDefinition {
+func (i OneloginAppsImportable) ImportFromRemote(searchId *string) ([]ResourceDefinition, error) {
 	var remoteApps []apps.App
 	if searchId == nil || *searchId == "" {
 		fmt.Println("Collecting Apps from OneLogin...")
-		remoteApps = i.getAllOneLoginApps()
+		var err error
+		if remoteApps, err = i.getAllOneLoginApps(); err != nil {
+			return nil, err
+		}
 	} else {
 		fmt.Printf("Collecting App %s from OneLogin...\n", *searchId)
 		id, err := strconv.Atoi(*searchId)
 		if err != nil {
-			log.Fatalln("invalid input given for id", *searchId)
+			return nil, fmt.Errorf("invalid input given for id %s", *searchId)
 		}
 		app, err := i.Service.GetOne(int32(id))
 		if err != nil {
-			log.Fatalln("Unable to locate resource with id", id)
+			return nil, fmt.Errorf("unable to locate resource with id %d: %s", id, err)
 		}
 		remoteApps = []apps.App{*app}
 	}
 	resourceDefinitions := assembleOneLoginResourceDefinitions(remoteApps)
-	return resourceDefinitions
+	return resourceDefinitions, nil
 }
 
 // helper for packing apps into ResourceDefinitions
@@ -65,7 +97,7 @@ func assembleOneLoginResourceDefinitions(allApps []apps.App) []ResourceDefinitio
 }
 
 // Makes the HTTP call to the remote to get the apps using the given query parameters
-func (i OneloginAppsImportable) getAllOneLoginApps() []apps.App {
+func (i OneloginAppsImportable) getAllOneLoginApps() ([]apps.App, error) {
 
 	appTypeQueryMap := map[string]string{
 		"onelogin_apps":      "",
@@ -78,10 +110,10 @@ func (i OneloginAppsImportable) getAllOneLoginApps() []apps.App {
 		AuthMethod: requestedAppType,
 	})
 	if err != nil {
-		log.Fatal("error retrieving apps ", err)
+		return nil, fmt.Errorf("error retrieving apps: %s", err)
 	}
 
-	return appApps
+	return appApps, nil
 }
 
 func (i OneloginAppsImportable) HCLShape() interface{} {
@@ -90,6 +122,7 @@ func (i OneloginAppsImportable) HCLShape() interface{} {
 
 // the underlying data that represents the resource from the remote in terraform.
 // add fields here so they can be unmarshalled from tfstate json into the struct and handled by the importer
+// sso, which holds the OIDC client secret and SAML certificate, is computed by the provider and left out on purpose
 type AppData struct {
 	AllowAssumedSignin *bool                `json:"allow_assumed_signin,omitempty"`
 	ConnectorID        *int32               `json:"connector_id,omitempty"`
@@ -4,10 +4,26 @@ import (
 	"fmt"
 	"github.com/onelogin/onelogin-go-sdk/pkg/services/roles"
 	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
-	"log"
+	"github.com/onelogin/onelogin/clients"
 	"strconv"
 )
 
+func init() {
+	Register(Registration{
+		Name:        "onelogin_roles",
+		Types:       []string{"onelogin_roles"},
+		Client:      ClientOneLogin,
+		Description: "onelogin roles",
+		New: func(clientList *clients.Clients) (Importable, error) {
+			oneloginClient, err := clientList.LoadOneLoginClient()
+			if err != nil {
+				return nil, err
+			}
+			return &OneloginRolesImportable{Service: oneloginClient.Services.RolesV1}, nil
+		},
+	})
+}
+
 type RoleQuerier interface {
 	Query(query *roles.RoleQuery) ([]roles.Role, error)
 	GetOne(id int32) (*roles.Role, error)
@@ -19,24 +35,24 @@ type OneloginRolesImportable struct {
 
 // Interface requirement to be an Importable. Calls out to remote (onelogin api) and
 // creates their Terraform ResourceDefinitions
-func (i OneloginRolesImportable) ImportFromRemote(searchId *string) []ResourceDefinition {
+func (i OneloginRolesImportable) ImportFromRemote(searchId *string) ([]ResourceDefinition, error) {
 	out := []roles.Role{}
 	var err error
 	if searchId == nil || *searchId == "" {
 		fmt.Println("Collecting Roles from OneLogin...")
 		out, err = i.Service.Query(nil) // Todo, interface to pass these queries down
 		if err != nil {
-			log.Fatalln("Unable to get roles", err)
+			return nil, fmt.Errorf("unable to get roles: %s", err)
 		}
 	} else {
 		fmt.Printf("Collecting Role %s from OneLogin...\n", *searchId)
 		id, err := strconv.Atoi(*searchId)
 		if err != nil {
-			log.Fatalln("invalid input given for id", *searchId)
+			return nil, fmt.Errorf("invalid input given for id %s", *searchId)
 		}
 		role, err := i.Service.GetOne(int32(id))
 		if err != nil {
-			log.Fatalln("Unable to locate resource with id", id)
+			return nil, fmt.Errorf("unable to locate resource with id %d: %s", id, err)
 		}
 		out = append(out, *role)
 	}
@@ -49,16 +65,17 @@ func (i OneloginRolesImportable) ImportFromRemote(searchId *string) []ResourceDe
 			ImportID: fmt.Sprintf("%d", *rd.ID),
 		}
 	}
-	return resourceDefinitions
+	return resourceDefinitions, nil
 }
 
 func (i OneloginRolesImportable) HCLShape() interface{} {
 	return &Role{}
 }
 
+// Role is the HCL shape of a role. The ref tag names the resource types an id attribute refers to.
 type Role struct {
 	Name   *string `json:"name,omitempty"`
-	Admins []int32 `json:"admins,omitempty"`
-	Apps   []int32 `json:"apps,omitempty"`
-	Users  []int32 `json:"users,omitempty"`
+	Admins []int32 `json:"admins,omitempty" ref:"onelogin_users"`
+	Apps   []int32 `json:"apps,omitempty" ref:"onelogin_apps,onelogin_saml_apps,onelogin_oidc_apps"`
+	Users  []int32 `json:"users,omitempty" ref:"onelogin_users"`
 }
@@ -1,6 +1,8 @@
 package tfimportables
 
 import (
+	"errors"
+
 	"github.com/onelogin/onelogin-go-sdk/pkg/oltypes"
 	"github.com/onelogin/onelogin-go-sdk/pkg/services/roles"
 	"github.com/stretchr/testify/assert"
@@ -43,8 +45,45 @@ func TestImportRoleFromRemote(t *testing.T) {
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			actual := test.Importable.ImportFromRemote(test.SearchID)
+			actual, err := test.Importable.ImportFromRemote(test.SearchID)
+			assert.Nil(t, err)
 			assert.Equal(t, test.Expected, actual)
 		})
 	}
 }
+
+type MockFailingRolesService struct{}
+
+func (svc MockFailingRolesService) Query(query *roles.RoleQuery) ([]roles.Role, error) {
+	return nil, errors.New("unauthorized")
+}
+
+func (svc MockFailingRolesService) GetOne(id int32) (*roles.Role, error) {
+	return nil, errors.New("not found")
+}
+
+func TestImportRoleFromRemoteErrors(t *testing.T) {
+	tests := map[string]struct {
+		SearchID   *string
+		Importable OneloginRolesImportable
+	}{
+		"It returns the error getting all roles": {
+			Importable: OneloginRolesImportable{Service: MockFailingRolesService{}},
+		},
+		"It returns the error getting one role": {
+			SearchID:   oltypes.String("1"),
+			Importable: OneloginRolesImportable{Service: MockFailingRolesService{}},
+		},
+		"It returns an error for an invalid id": {
+			SearchID:   oltypes.String("abc"),
+			Importable: OneloginRolesImportable{Service: MockRolesService{}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := test.Importable.ImportFromRemote(test.SearchID)
+			assert.Error(t, err)
+			assert.Nil(t, actual)
+		})
+	}
+}
@@ -2,12 +2,30 @@ package tfimportables
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/onelogin/onelogin-go-sdk/pkg/services/smarthooks/envs"
 	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
+
+	"github.com/onelogin/onelogin/clients"
 )
 
+func init() {
+	Register(Registration{
+		Name:        "onelogin_smarthook_env_vars",
+		Aliases:     []string{"onelogin_smarthook_environment_variables"},
+		Types:       []string{"onelogin_smarthook_environment_variables"},
+		Client:      ClientOneLogin,
+		Description: "onelogin smarthook environment variables",
+		New: func(clientList *clients.Clients) (Importable, error) {
+			oneloginClient, err := clientList.LoadOneLoginClient()
+			if err != nil {
+				return nil, err
+			}
+			return &OneloginSmartHookEnvVarsImportable{Service: oneloginClient.Services.SmartHooksEnvVarsV1}, nil
+		},
+	})
+}
+
 type SmartHookEnvVarQuerier interface {
 	Query(query *smarthookenvs.SmartHookEnvVarQuery) ([]smarthookenvs.EnvVar, error)
 	GetOne(id string) (*smarthookenvs.EnvVar, error)
@@ -19,20 +37,20 @@ type OneloginSmartHookEnvVarsImportable struct {
 
 // Interface requirement to be an Importable. Calls out to remote (onelogin api) and
 // creates their Terraform ResourceDefinitions
-func (i OneloginSmartHookEnvVarsImportable) ImportFromRemote(searchId *string) []ResourceDefinition {
+func (i OneloginSmartHookEnvVarsImportable) ImportFromRemote(searchId *string) ([]ResourceDefinition, error) {
 	out := []smarthookenvs.EnvVar{}
 	var err error
 	if searchId == nil || *searchId == "" {
 		fmt.Println("Collecting SmartHooks from OneLogin...")
 		out, err = i.Service.Query(nil) // Todo, interface to pass these queries down
 		if err != nil {
-			log.Fatalln("Unable to get SmartHooks", err)
+			return nil, fmt.Errorf("unable to get SmartHooks: %s", err)
 		}
 	} else {
 		fmt.Printf("Collecting SmartHook %s from OneLogin...\n", *searchId)
 		smarthook, err := i.Service.GetOne(*searchId)
 		if err != nil {
-			log.Fatalln("Unable to locate resource with id", searchId)
+			return nil, fmt.Errorf("unable to locate resource with id %s: %s", *searchId, err)
 		}
 		out = append(out, *smarthook)
 	}
@@ -45,9 +63,19 @@ func (i OneloginSmartHookEnvVarsImportable) ImportFromRemote(searchId *string) [
 			ImportID: fmt.Sprintf("%s", *rd.ID),
 		}
 	}
-	return resourceDefinitions
+	return resourceDefinitions, nil
 }
 
 func (i OneloginSmartHookEnvVarsImportable) HCLShape() interface{} {
-	return &smarthookenvs.EnvVar{}
+	return &EnvVarData{}
+}
+
+// EnvVarData is the HCL shape of a smart hook environment variable. The sensitive tag keeps its value out of the
+// generated configuration.
+type EnvVarData struct {
+	ID        *string `json:"id,omitempty"`
+	Name      *string `json:"name,omitempty"`
+	Value     *string `json:"value,omitempty" sensitive:"true"`
+	CreatedAt *string `json:"created_at,omitempty"`
+	UpdatedAt *string `json:"updated_at,omitempty"`
 }
@@ -43,7 +43,8 @@ func TestImportEnvVarFromRemote(t *testing.T) {
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			actual := test.Importable.ImportFromRemote(test.SearchID)
+			actual, err := test.Importable.ImportFromRemote(test.SearchID)
+			assert.Nil(t, err)
 			assert.Equal(t, test.Expected, actual)
 		})
 	}
@@ -2,12 +2,29 @@ package tfimportables
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/onelogin/onelogin-go-sdk/pkg/services/smarthooks"
 	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
+
+	"github.com/onelogin/onelogin/clients"
 )
 
+func init() {
+	Register(Registration{
+		Name:        "onelogin_smarthooks",
+		Types:       []string{"onelogin_smarthooks"},
+		Client:      ClientOneLogin,
+		Description: "onelogin smarthooks",
+		New: func(clientList *clients.Clients) (Importable, error) {
+			oneloginClient, err := clientList.LoadOneLoginClient()
+			if err != nil {
+				return nil, err
+			}
+			return &OneloginSmartHooksImportable{Service: oneloginClient.Services.SmartHooksV1}, nil
+		},
+	})
+}
+
 type SmartHookQuerier interface {
 	Query(query *smarthooks.SmartHookQuery) ([]smarthooks.SmartHook, error)
 	GetOne(id string) (*smarthooks.SmartHook, error)
@@ -19,20 +36,20 @@ type OneloginSmartHooksImportable struct {
 
 // Interface requirement to be an Importable. Calls out to remote (onelogin api) and
 // creates their Terraform ResourceDefinitions
-func (i OneloginSmartHooksImportable) ImportFromRemote(searchId *string) []ResourceDefinition {
+func (i OneloginSmartHooksImportable) ImportFromRemote(searchId *string) ([]ResourceDefinition, error) {
 	out := []smarthooks.SmartHook{}
 	var err error
 	if searchId == nil || *searchId == "" {
 		fmt.Println("Collecting SmartHooks from OneLogin...")
 		out, err = i.Service.Query(nil) // Todo, interface to pass these queries down
 		if err != nil {
-			log.Fatalln("Unable to get SmartHooks", err)
+			return nil, fmt.Errorf("unable to get SmartHooks: %s", err)
 		}
 	} else {
 		fmt.Printf("Collecting SmartHook %s from OneLogin...\n", *searchId)
 		smarthook, err := i.Service.GetOne(*searchId)
 		if err != nil {
-			log.Fatalln("Unable to locate resource with id", searchId)
+			return nil, fmt.Errorf("unable to locate resource with id %s: %s", *searchId, err)
 		}
 		out = append(out, *smarthook)
 	}
@@ -46,14 +63,15 @@ func (i OneloginSmartHooksImportable) ImportFromRemote(searchId *string) []Resou
 			ImportID: fmt.Sprintf("%s", *rd.ID),
 		}
 	}
-	return resourceDefinitions
+	return resourceDefinitions, nil
 }
 
 func (i OneloginSmartHooksImportable) HCLShape() interface{} {
 	return &SmartHook{}
 }
 
-// SmartHook represents a OneLogin SmartHook with associated resource data
+// SmartHook represents a OneLogin SmartHook with associated resource data. The base64file tag writes the decoded
+// function to hooks/<resource name>.js, laid out like the hook.js of smarthooks get, so it can be reviewed as code.
 type SmartHook struct {
 	ID             *string           `json:"id,omitempty"`
 	Type           *string           `json:"type,omitempty"`
@@ -65,7 +83,7 @@ type SmartHook struct {
 	Retries        *int32            `json:"retries,omitempty"`
 	Options        *Options          `json:"options,omitempty"`
 	Packages       map[string]string `json:"packages,omitempty"`
-	Function       *string           `json:"function,omitempty"`
+	Function       *string           `json:"function,omitempty" base64file:"hooks/*.js"`
 	Status         *string           `json:"status,omitempty"`
 	Conditions     []Condition       `json:"conditions,omitempty"`
 }
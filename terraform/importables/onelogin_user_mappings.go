@@ -4,10 +4,26 @@ import (
 	"fmt"
 	"github.com/onelogin/onelogin-go-sdk/pkg/services/user_mappings"
 	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
-	"log"
+	"github.com/onelogin/onelogin/clients"
 	"strconv"
 )
 
+func init() {
+	Register(Registration{
+		Name:        "onelogin_user_mappings",
+		Types:       []string{"onelogin_user_mappings"},
+		Client:      ClientOneLogin,
+		Description: "onelogin user mappings",
+		New: func(clientList *clients.Clients) (Importable, error) {
+			oneloginClient, err := clientList.LoadOneLoginClient()
+			if err != nil {
+				return nil, err
+			}
+			return &OneloginUserMappingsImportable{Service: oneloginClient.Services.UserMappingsV2}, nil
+		},
+	})
+}
+
 type UserMappingQuerier interface {
 	Query(query *usermappings.UserMappingsQuery) ([]usermappings.UserMapping, error)
 	GetOne(id int32) (*usermappings.UserMapping, error)
@@ -19,25 +35,28 @@ type OneloginUserMappingsImportable struct {
 
 // Interface requirement to be an Importable. Calls out to remote (onelogin api) and
 // creates their Terraform ResourceDefinitions
-func (i OneloginUserMappingsImportable) ImportFromRemote(searchId *string) []ResourceDefinition {
+func (i OneloginUserMappingsImportable) ImportFromRemote(searchId *string) ([]ResourceDefinition, error) {
 	var remoteUserMappings []usermappings.UserMapping
 	if searchId == nil || *searchId == "" {
 		fmt.Println("Collecting User Mappings from OneLogin...")
-		remoteUserMappings = i.getOneLoginUserMappings()
+		var err error
+		if remoteUserMappings, err = i.getOneLoginUserMappings(); err != nil {
+			return nil, err
+		}
 	} else {
 		fmt.Printf("Collecting User Mapping %s from OneLogin...\n", *searchId)
 		id, err := strconv.Atoi(*searchId)
 		if err != nil {
-			log.Fatalln("invalid input given for id", *searchId)
+			return nil, fmt.Errorf("invalid input given for id %s", *searchId)
 		}
 		userMapping, err := i.Service.GetOne(int32(id))
 		if err != nil {
-			log.Fatalln("Unable to locate resource with id", id)
+			return nil, fmt.Errorf("unable to locate resource with id %d: %s", id, err)
 		}
 		remoteUserMappings = []usermappings.UserMapping{*userMapping}
 	}
 	resourceDefinitions := assembleUserMappingResourceDefinitions(remoteUserMappings)
-	return resourceDefinitions
+	return resourceDefinitions, nil
 }
 
 // helper for packing apps into ResourceDefinitions
@@ -55,12 +74,12 @@ func assembleUserMappingResourceDefinitions(allUserMappings []usermappings.UserM
 }
 
 // Makes the HTTP call to the remote to get the apps using the given query parameters
-func (i OneloginUserMappingsImportable) getOneLoginUserMappings() []usermappings.UserMapping {
+func (i OneloginUserMappingsImportable) getOneLoginUserMappings() ([]usermappings.UserMapping, error) {
 	um, err := i.Service.Query(&usermappings.UserMappingsQuery{})
 	if err != nil {
-		log.Fatal("error retrieving apps ", err)
+		return nil, fmt.Errorf("error retrieving user mappings: %s", err)
 	}
-	return um
+	return um, nil
 }
 
 func (i OneloginUserMappingsImportable) HCLShape() interface{} {
@@ -67,7 +67,8 @@ func TestImportUserMappingFromRemote(t *testing.T) {
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			actual := test.Importable.ImportFromRemote(test.SearchID)
+			actual, err := test.Importable.ImportFromRemote(test.SearchID)
+			assert.Nil(t, err)
 			assert.Equal(t, test.Expected, actual)
 		})
 	}
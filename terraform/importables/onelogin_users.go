@@ -4,10 +4,26 @@ import (
 	"fmt"
 	"github.com/onelogin/onelogin-go-sdk/pkg/services/users"
 	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
-	"log"
+	"github.com/onelogin/onelogin/clients"
 	"strconv"
 )
 
+func init() {
+	Register(Registration{
+		Name:        "onelogin_users",
+		Types:       []string{"onelogin_users"},
+		Client:      ClientOneLogin,
+		Description: "onelogin users",
+		New: func(clientList *clients.Clients) (Importable, error) {
+			oneloginClient, err := clientList.LoadOneLoginClient()
+			if err != nil {
+				return nil, err
+			}
+			return &OneloginUsersImportable{Service: oneloginClient.Services.UsersV2}, nil
+		},
+	})
+}
+
 type UserQuerier interface {
 	Query(query *users.UserQuery) ([]users.User, error)
 	GetOne(id int32) (*users.User, error)
@@ -19,24 +35,24 @@ type OneloginUsersImportable struct {
 
 // Interface requirement to be an Importable. Calls out to remote (onelogin api) and
 // creates their Terraform ResourceDefinitions
-func (i OneloginUsersImportable) ImportFromRemote(searchId *string) []ResourceDefinition {
+func (i OneloginUsersImportable) ImportFromRemote(searchId *string) ([]ResourceDefinition, error) {
 	out := []users.User{}
 	var err error
 	if searchId == nil || *searchId == "" {
 		fmt.Println("Collecting Users from OneLogin...")
 		out, err = i.Service.Query(nil) // Todo, interface to pass these queries down
 		if err != nil {
-			log.Fatalln("Unable to get users", err)
+			return nil, fmt.Errorf("unable to get users: %s", err)
 		}
 	} else {
 		fmt.Printf("Collecting User %s from OneLogin...\n", *searchId)
 		id, err := strconv.Atoi(*searchId)
 		if err != nil {
-			log.Fatalln("invalid input given for id", *searchId)
+			return nil, fmt.Errorf("invalid input given for id %s", *searchId)
 		}
 		user, err := i.Service.GetOne(int32(id))
 		if err != nil {
-			log.Fatalln("Unable to locate resource with id", id)
+			return nil, fmt.Errorf("unable to locate resource with id %d: %s", id, err)
 		}
 		out = append(out, *user)
 	}
@@ -50,7 +66,7 @@ func (i OneloginUsersImportable) ImportFromRemote(searchId *string) []ResourceDe
 			ImportID: fmt.Sprintf("%d", *rd.ID),
 		}
 	}
-	return resourceDefinitions
+	return resourceDefinitions, nil
 }
 
 func (i OneloginUsersImportable) HCLShape() interface{} {
@@ -58,7 +74,8 @@ func (i OneloginUsersImportable) HCLShape() interface{} {
 }
 
 // the underlying data that represents the resource from the remote in terraform.
-// add fields here so they can be unmarshalled from tfstate json into the struct and handled by the importer
+// add fields here so they can be unmarshalled from tfstate json into the struct and handled by the importer.
+// the ref tag names the resource types an id attribute refers to
 type UserData struct {
 	Firstname            *string `json:"firstname,omitempty"`
 	Lastname             *string `json:"lastname,omitempty"`
@@ -80,6 +97,6 @@ type UserData struct {
 	DirectoryID          *int32  `json:"directory_id,omitempty"`
 	TrustedIDPID         *int32  `json:"trusted_idp_id,omitempty"`
 	ManagerADID          *int32  `json:"manager_ad_id,omitempty"`
-	ManagerUserID        *int32  `json:"manager_user_id,omitempty"`
+	ManagerUserID        *int32  `json:"manager_user_id,omitempty" ref:"onelogin_users"`
 	ExternalID           *int32  `json:"external_id,omitempty"`
 }
@@ -0,0 +1,108 @@
+package tfimportables
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/onelogin/onelogin/clients"
+)
+
+// The remotes an importable can read from
+const (
+	ClientOneLogin = "onelogin"
+	ClientOkta     = "okta"
+	ClientAWS      = "aws"
+)
+
+// Registration describes an importable so it can be looked up by name and listed
+type Registration struct {
+	Name        string                                                // name the importable is requested by, e.g. onelogin_apps
+	Aliases     []string                                              // other names that request it
+	Types       []string                                              // Terraform resource types it produces
+	Client      string                                                // remote it reads from, e.g. ClientOneLogin
+	Description string                                                // one line summary shown by terraform-import --list
+	New         func(clientList *clients.Clients) (Importable, error) // creates the importable with the client it needs
+	SubsetOf    string                                                // name of an importable that imports everything this one does. Left out of All
+}
+
+// All requests every OneLogin importable from Resolve
+const All = "all"
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]*Registration{} // keyed by name and every alias
+)
+
+// Register makes an importable available by its name and aliases. Programs embedding the importer can register
+// their own from an init function. It panics if a name is empty or already registered, like database/sql.Register.
+func Register(r Registration) {
+	if r.Name == "" || r.New == nil {
+		panic("tfimportables: Register requires a name and a constructor")
+	}
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	names := append([]string{r.Name}, r.Aliases...)
+	for _, name := range names {
+		if _, dup := registry[name]; dup {
+			panic(fmt.Sprintf("tfimportables: Register called twice for %s", name))
+		}
+	}
+	for _, name := range names {
+		registry[name] = &r
+	}
+}
+
+// Lookup returns the registration for a name or alias
+func Lookup(name string) (Registration, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	r, ok := registry[name]
+	if !ok {
+		return Registration{}, false
+	}
+	return *r, true
+}
+
+// Registrations returns every registered importable once, sorted by name
+func Registrations() []Registration {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	out := []Registration{}
+	for name, r := range registry {
+		if name == r.Name {
+			out = append(out, *r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Resolve returns the registrations for the requested names or aliases in the order given, each once.
+// All expands to every OneLogin importable that isn't a subset of another.
+func Resolve(names []string) ([]Registration, error) {
+	out := []Registration{}
+	seen := map[string]bool{}
+	add := func(r Registration) {
+		if !seen[r.Name] {
+			seen[r.Name] = true
+			out = append(out, r)
+		}
+	}
+	for _, name := range names {
+		if name == All {
+			for _, r := range Registrations() {
+				if r.Client == ClientOneLogin && r.SubsetOf == "" {
+					add(r)
+				}
+			}
+			continue
+		}
+		r, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("the importable %s is not configured. Run terraform-import --list to see those available", name)
+		}
+		add(r)
+	}
+	return out, nil
+}
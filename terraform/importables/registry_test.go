@@ -0,0 +1,105 @@
+package tfimportables
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/onelogin/onelogin/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+type testImportable struct{}
+
+func (i testImportable) ImportFromRemote(searchId *string) ([]ResourceDefinition, error) {
+	return nil, nil
+}
+func (i testImportable) HCLShape() interface{} { return nil }
+
+func TestRegister(t *testing.T) {
+	Register(Registration{
+		Name:        "test_widgets",
+		Aliases:     []string{"test_gadgets"},
+		Types:       []string{"test_widget"},
+		Client:      "test", // keeps it out of All
+		Description: "test widgets",
+		New:         func(clientList *clients.Clients) (Importable, error) { return testImportable{}, nil },
+	})
+	tests := map[string]struct {
+		Name          string
+		ExpectedName  string
+		ExpectedFound bool
+	}{
+		"It finds an importable by name":       {Name: "test_widgets", ExpectedName: "test_widgets", ExpectedFound: true},
+		"It finds an importable by alias":      {Name: "test_gadgets", ExpectedName: "test_widgets", ExpectedFound: true},
+		"It finds the built in importables":    {Name: "onelogin_smarthook_environment_variables", ExpectedName: "onelogin_smarthook_env_vars", ExpectedFound: true},
+		"It does not find unknown importables": {Name: "test_gizmos"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			registration, ok := Lookup(test.Name)
+			assert.Equal(t, test.ExpectedFound, ok)
+			assert.Equal(t, test.ExpectedName, registration.Name)
+		})
+	}
+
+	assert.Panics(t, func() {
+		Register(Registration{Name: "test_gizmos", Aliases: []string{"test_gadgets"}, New: func(clientList *clients.Clients) (Importable, error) { return nil, nil }})
+	}, "aliases cannot be registered twice")
+	_, ok := Lookup("test_gizmos")
+	assert.False(t, ok, "a failed registration should register none of its names")
+
+	names := []string{}
+	for _, registration := range Registrations() {
+		names = append(names, registration.Name)
+	}
+	assert.Contains(t, names, "test_widgets")
+	assert.NotContains(t, names, "test_gadgets", "aliases should not be listed separately")
+	assert.True(t, sort.StringsAreSorted(names))
+}
+
+func TestResolve(t *testing.T) {
+	tests := map[string]struct {
+		Names         []string
+		Expected      []string
+		ExpectedError bool
+	}{
+		"It resolves names and aliases in the order given": {
+			Names:    []string{"onelogin_users", "okta_app_saml", "onelogin_roles"},
+			Expected: []string{"onelogin_users", "okta_apps", "onelogin_roles"},
+		},
+		"It resolves an importable requested twice once": {
+			Names:    []string{"okta_apps", "okta_app_saml"},
+			Expected: []string{"okta_apps"},
+		},
+		"It expands all to every OneLogin importable except subsets": {
+			Names: []string{All, "onelogin_users"},
+			Expected: []string{
+				"onelogin_apps",
+				"onelogin_roles",
+				"onelogin_smarthook_env_vars",
+				"onelogin_smarthooks",
+				"onelogin_user_mappings",
+				"onelogin_users",
+			},
+		},
+		"It rejects unknown names": {
+			Names:         []string{"onelogin_users", "onelogin_widgets"},
+			ExpectedError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			registrations, err := Resolve(test.Names)
+			if test.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			names := []string{}
+			for _, r := range registrations {
+				names = append(names, r.Name)
+			}
+			assert.Equal(t, test.Expected, names)
+		})
+	}
+}
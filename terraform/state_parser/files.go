@@ -0,0 +1,49 @@
+package stateparser
+
+import (
+	"encoding/base64"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Files are the contents of the files that attributes are read from in the generated HCL, by path relative to it
+type Files map[string][]byte
+
+// extract moves the attributes tagged base64file in the resource's HCL shape into files, and replaces them with
+// base64encode(file(...)) of the file. The tag gives the file's path, where * is the resource's name. Values
+// that aren't base64 are written to the file as they are and read back with file(...) alone.
+func (files Files) extract(resource StateResource, hclShape interface{}, data map[string]interface{}, suffix string) {
+	for key, pattern := range taggedFields(hclShape, "base64file") {
+		value, ok := data[key].(string)
+		if !ok || value == "" {
+			continue
+		}
+		filePath := strings.Replace(pattern, "*", resource.Name+suffix, 1)
+		fileTokens := hclwrite.TokensForFunctionCall("file", modulePathTokens(filePath))
+		if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+			files[filePath] = decoded
+			data[key] = hclwrite.TokensForFunctionCall("base64encode", fileTokens)
+		} else {
+			files[filePath] = []byte(value)
+			data[key] = fileTokens
+		}
+	}
+}
+
+// modulePathTokens returns the tokens of "${path.module}/<filePath>", so the file is found wherever the
+// configuration is used from
+func modulePathTokens(filePath string) hclwrite.Tokens {
+	return hclwrite.Tokens{
+		{Type: hclsyntax.TokenOQuote, Bytes: []byte(`"`)},
+		{Type: hclsyntax.TokenTemplateInterp, Bytes: []byte(`${`)},
+		{Type: hclsyntax.TokenIdent, Bytes: []byte("path")},
+		{Type: hclsyntax.TokenDot, Bytes: []byte(".")},
+		{Type: hclsyntax.TokenIdent, Bytes: []byte("module")},
+		{Type: hclsyntax.TokenTemplateSeqEnd, Bytes: []byte("}")},
+		{Type: hclsyntax.TokenQuotedLit, Bytes: []byte("/" + path.Clean(filePath))},
+		{Type: hclsyntax.TokenCQuote, Bytes: []byte(`"`)},
+	}
+}
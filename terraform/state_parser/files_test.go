@@ -0,0 +1,48 @@
+package stateparser
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilesExtract(t *testing.T) {
+	type shape struct {
+		Name *string `json:"name,omitempty"`
+		Code *string `json:"code,omitempty" base64file:"code/*.js"`
+	}
+	tests := map[string]struct {
+		Code             string
+		ExpectedFiles    Files
+		ExpectedFunction string
+	}{
+		"It decodes base64 values into the file and encodes the file back": {
+			Code:             "Y29uc29sZS5sb2coMSk7Cg==",
+			ExpectedFiles:    Files{"code/test.js": []byte("console.log(1);\n")},
+			ExpectedFunction: `base64encode(file("${path.module}/code/test.js"))`,
+		},
+		"It writes other values as they are": {
+			Code:             "console.log(1);\n",
+			ExpectedFiles:    Files{"code/test.js": []byte("console.log(1);\n")},
+			ExpectedFunction: `file("${path.module}/code/test.js")`,
+		},
+		"It leaves empty values alone": {
+			ExpectedFiles: Files{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files := Files{}
+			data := map[string]interface{}{"name": "n"}
+			if test.Code != "" {
+				data["code"] = test.Code
+			}
+			files.extract(StateResource{Type: "test", Name: "test"}, &shape{}, data, "")
+			assert.Equal(t, test.ExpectedFiles, files)
+			if test.ExpectedFunction != "" {
+				assert.Equal(t, test.ExpectedFunction, string(data["code"].(hclwrite.Tokens).Bytes()))
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package stateparser
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/onelogin/onelogin/terraform/importables"
+)
+
+// IDIndex finds the resource in the configuration with a given import id, by resource type then id
+type IDIndex map[string]map[string]string
+
+// NewIDIndex indexes the names of the resources by type and import id
+func NewIDIndex(resourceDefinitions []tfimportables.ResourceDefinition) IDIndex {
+	index := IDIndex{}
+	for _, resourceDefinition := range resourceDefinitions {
+		if index[resourceDefinition.Type] == nil {
+			index[resourceDefinition.Type] = map[string]string{}
+		}
+		index[resourceDefinition.Type][resourceDefinition.ImportID] = resourceDefinition.Name
+	}
+	return index
+}
+
+// reference returns the traversal to the id of the first of the types to have a resource with the id
+func (index IDIndex) reference(types []string, id string) (hcl.Traversal, bool) {
+	for _, resourceType := range types {
+		if name, ok := index[resourceType][id]; ok {
+			return hcl.Traversal{
+				hcl.TraverseRoot{Name: resourceType},
+				hcl.TraverseAttr{Name: name},
+				hcl.TraverseAttr{Name: "id"},
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// ReplaceIDsWithReferences rewrites the ids in the resources' id attributes, those tagged with ref in their
+// importable's HCL shape, as references to the resources in the index with those ids, e.g. apps = [123] becomes
+// apps = [onelogin_saml_apps.foo.id], so Terraform knows the resources depend on each other. Ids of resources
+// not in the index are left as they are.
+func ReplaceIDsWithReferences(src []byte, importables *tfimportables.ImportableList, index IDIndex) ([]byte, error) {
+	file, diags := hclwrite.ParseConfig(src, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "resource" || len(block.Labels()) != 2 {
+			continue
+		}
+		importable, err := importables.Importable(block.Labels()[0])
+		if err != nil {
+			continue // not a resource the importer knows
+		}
+		for name, types := range referenceTags(importable.HCLShape()) {
+			if attribute := block.Body().GetAttribute(name); attribute != nil {
+				block.Body().SetAttributeRaw(name, referenceTokens(attribute.Expr().BuildTokens(nil), types, index))
+			}
+		}
+	}
+	return hclwrite.Format(file.Bytes()), nil
+}
+
+// referenceTokens replaces the number and string literals in the tokens that are ids in the index with references
+func referenceTokens(tokens hclwrite.Tokens, types []string, index IDIndex) hclwrite.Tokens {
+	replaced := hclwrite.Tokens{}
+	for i := 0; i < len(tokens); i++ {
+		id, n := "", 1
+		switch {
+		case tokens[i].Type == hclsyntax.TokenNumberLit:
+			id = string(tokens[i].Bytes)
+		case tokens[i].Type == hclsyntax.TokenOQuote && i+2 < len(tokens) &&
+			tokens[i+1].Type == hclsyntax.TokenQuotedLit && tokens[i+2].Type == hclsyntax.TokenCQuote:
+			id, n = string(tokens[i+1].Bytes), 3
+		}
+		if traversal, ok := index.reference(types, id); id != "" && ok {
+			replaced = append(replaced, hclwrite.TokensForTraversal(traversal)...)
+			i += n - 1
+			continue
+		}
+		replaced = append(replaced, tokens[i])
+	}
+	return replaced
+}
+
+// referenceTags returns the types each attribute of the HCL shape refers to, from its ref tag, by attribute name
+func referenceTags(hclShape interface{}) map[string][]string {
+	tags := map[string][]string{}
+	for name, ref := range taggedFields(hclShape, "ref") {
+		tags[name] = strings.Split(ref, ",")
+	}
+	return tags
+}
@@ -0,0 +1,92 @@
+package stateparser
+
+import (
+	"testing"
+
+	"github.com/onelogin/onelogin/clients"
+	tfimportables "github.com/onelogin/onelogin/terraform/importables"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceIDsWithReferences(t *testing.T) {
+	index := NewIDIndex([]tfimportables.ResourceDefinition{
+		{Type: "onelogin_saml_apps", Name: "foo", ImportID: "123"},
+		{Type: "onelogin_oidc_apps", Name: "bar", ImportID: "456"},
+		{Type: "onelogin_users", Name: "jane", ImportID: "7"},
+		{Type: "onelogin_roles", Name: "admins", ImportID: "8"},
+	})
+	tests := map[string]struct {
+		Input          string
+		ExpectedOutput string
+	}{
+		"It replaces ids of resources in the index with references": {
+			Input: `resource "onelogin_roles" "admins" {
+  apps  = [123, 456]
+  name  = "Admins"
+  users = [7]
+}
+`,
+			ExpectedOutput: `resource "onelogin_roles" "admins" {
+  apps  = [onelogin_saml_apps.foo.id, onelogin_oidc_apps.bar.id]
+  name  = "Admins"
+  users = [onelogin_users.jane.id]
+}
+`,
+		},
+		"It leaves ids of resources not in the index as they are": {
+			Input: `resource "onelogin_roles" "users" {
+  admins = [7, 99]
+  apps   = [8]
+}
+`,
+			ExpectedOutput: `resource "onelogin_roles" "users" {
+  admins = [onelogin_users.jane.id, 99]
+  apps   = [8]
+}
+`,
+		},
+		"It replaces single ids and leaves untagged attributes alone": {
+			Input: `resource "onelogin_users" "john" {
+  group_id        = 7
+  manager_user_id = 7
+}
+
+resource "unknown_type" "x" {
+  users = [7]
+}
+`,
+			ExpectedOutput: `resource "onelogin_users" "john" {
+  group_id        = 7
+  manager_user_id = onelogin_users.jane.id
+}
+
+resource "unknown_type" "x" {
+  users = [7]
+}
+`,
+		},
+	}
+	importables := tfimportables.New(&clients.Clients{
+		ClientConfigs: clients.ClientConfigs{
+			OneLoginClientID:     "ONELOGIN_CLIENT_ID",
+			OneLoginClientSecret: "ONELOGIN_CLIENT_SECRET",
+			OneLoginURL:          "ONELOGIN_OAPI_URL",
+		},
+	})
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ReplaceIDsWithReferences([]byte(test.Input), importables, index)
+			assert.Nil(t, err)
+			assert.Equal(t, test.ExpectedOutput, string(actual))
+		})
+	}
+}
+
+func TestReferenceTags(t *testing.T) {
+	assert.Equal(t, map[string][]string{
+		"admins": {"onelogin_users"},
+		"apps":   {"onelogin_apps", "onelogin_saml_apps", "onelogin_oidc_apps"},
+		"users":  {"onelogin_users"},
+	}, referenceTags(&tfimportables.Role{}))
+	assert.Equal(t, map[string][]string{}, referenceTags(&tfimportables.SmartHook{}))
+}
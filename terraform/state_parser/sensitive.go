@@ -0,0 +1,129 @@
+package stateparser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Secrets are the sensitive values taken out of the generated HCL, by the name of the variable that replaces them
+type Secrets map[string]interface{}
+
+// hide replaces the attributes tagged sensitive in the resource's HCL shape, including those of nested objects, with
+// references to variables and keeps their values. suffix tells apart the variables of a resource's instances.
+func (secrets Secrets) hide(resource StateResource, hclShape interface{}, data map[string]interface{}, suffix string) {
+	for _, path := range sensitivePaths(reflect.TypeOf(hclShape), map[reflect.Type]bool{}) {
+		parent := data
+		for _, key := range path[:len(path)-1] {
+			if parent, _ = parent[key].(map[string]interface{}); parent == nil {
+				break
+			}
+		}
+		key := path[len(path)-1]
+		if parent == nil || isEmpty(parent[key]) {
+			continue
+		}
+		names := make([]string, len(path))
+		for i, k := range path {
+			names[i] = utils.ToSnakeCase(k)
+		}
+		name := fmt.Sprintf("%s_%s_%s%s", resource.Type, resource.Name, strings.Join(names, "_"), suffix)
+		secrets[name] = parent[key]
+		parent[key] = hcl.Traversal{hcl.TraverseRoot{Name: "var"}, hcl.TraverseAttr{Name: name}}
+	}
+}
+
+// HasSensitiveFields reports whether any attribute of the HCL shape, or of the objects nested in it, is tagged sensitive
+func HasSensitiveFields(hclShape interface{}) bool {
+	return len(sensitivePaths(reflect.TypeOf(hclShape), map[reflect.Type]bool{})) > 0
+}
+
+// sensitivePaths returns the json keys leading to each field tagged sensitive in the struct, looking into nested
+// structs too. visiting guards against types that contain themselves.
+func sensitivePaths(shape reflect.Type, visiting map[reflect.Type]bool) [][]string {
+	for shape != nil && shape.Kind() == reflect.Ptr {
+		shape = shape.Elem()
+	}
+	if shape == nil || shape.Kind() != reflect.Struct || visiting[shape] {
+		return nil
+	}
+	visiting[shape] = true
+	defer delete(visiting, shape)
+	paths := [][]string{}
+	for i := 0; i < shape.NumField(); i++ {
+		field := shape.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := field.Tag.Lookup("sensitive"); ok {
+			paths = append(paths, []string{name})
+			continue
+		}
+		for _, nested := range sensitivePaths(field.Type, visiting) {
+			paths = append(paths, append([]string{name}, nested...))
+		}
+	}
+	return paths
+}
+
+// VariablesHCL declares a sensitive variable for each secret, so Terraform keeps their values out of its output
+func (secrets Secrets) VariablesHCL() []byte {
+	file := hclwrite.NewEmptyFile()
+	for i, name := range secrets.names() {
+		if i > 0 {
+			file.Body().AppendNewline()
+		}
+		file.Body().AppendNewBlock("variable", []string{name}).Body().SetAttributeValue("sensitive", cty.True)
+	}
+	return hclwrite.Format(file.Bytes())
+}
+
+// TFVars adds the secrets' values to the existing contents of a tfvars file, replacing those already set
+func (secrets Secrets) TFVars(existing []byte) ([]byte, error) {
+	file, diags := hclwrite.ParseConfig(existing, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	for _, name := range secrets.names() {
+		if value, ok := secrets[name].(string); ok && isHeredoc(value) {
+			file.Body().SetAttributeRaw(name, heredocTokens(value))
+			continue
+		}
+		file.Body().SetAttributeValue(name, ctyValue(secrets[name]))
+	}
+	return hclwrite.Format(file.Bytes()), nil
+}
+
+func (secrets Secrets) names() []string {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isEmpty reports whether the value would be left out of the HCL anyway, so there is nothing to hide
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package stateparser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretsTFVars(t *testing.T) {
+	tests := map[string]struct {
+		Secrets        Secrets
+		Existing       string
+		ExpectedOutput string
+	}{
+		"It writes each secret in name order": {
+			Secrets: Secrets{
+				"b_function": "line one\nline two\n",
+				"a_value":    "s3cr3t",
+				"c_object":   map[string]interface{}{"clientSecret": "x", "port": json.Number("443")},
+			},
+			ExpectedOutput: "a_value    = \"s3cr3t\"\nb_function = <<EOT\nline one\nline two\nEOT\nc_object = {\n  client_secret = \"x\"\n  port          = 443\n}\n",
+		},
+		"It keeps the existing values and comments and replaces values set again": {
+			Secrets:        Secrets{"a_value": "new", "c_value": "added"},
+			Existing:       "# from the last import\na_value = \"old\"\nb_value = \"kept\"\n",
+			ExpectedOutput: "# from the last import\na_value = \"new\"\nb_value = \"kept\"\nc_value = \"added\"\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := test.Secrets.TFVars([]byte(test.Existing))
+			assert.Nil(t, err)
+			assert.Equal(t, test.ExpectedOutput, string(actual))
+		})
+	}
+}
+
+func TestSecretsVariablesHCL(t *testing.T) {
+	secrets := Secrets{"b": "2", "a": "1"}
+	assert.Equal(t, "variable \"a\" {\n  sensitive = true\n}\n\nvariable \"b\" {\n  sensitive = true\n}\n", string(secrets.VariablesHCL()))
+	assert.Equal(t, "", string(Secrets{}.VariablesHCL()))
+}
+
+func TestSecretsHide(t *testing.T) {
+	type shape struct {
+		Name   *string `json:"name,omitempty"`
+		Secret *string `json:"secret,omitempty" sensitive:"true"`
+		Empty  *string `json:"empty,omitempty" sensitive:"true"`
+	}
+	secrets := Secrets{}
+	data := map[string]interface{}{"name": "n", "secret": "s"}
+	secrets.hide(StateResource{Type: "test", Name: "one"}, &shape{}, data, "_1")
+	assert.Equal(t, Secrets{"test_one_secret_1": "s"}, secrets)
+	assert.Equal(t, "n", data["name"])
+	assert.NotEqual(t, "s", data["secret"])
+	_, ok := data["empty"]
+	assert.False(t, ok)
+}
+
+func TestSecretsHideNested(t *testing.T) {
+	type client struct {
+		ID     string `json:"clientId,omitempty"`
+		Secret string `json:"clientSecret,omitempty" sensitive:"true"`
+	}
+	type shape struct {
+		Name   *string `json:"name,omitempty"`
+		Client *client `json:"client,omitempty"`
+		Other  *client `json:"other,omitempty"`
+	}
+	secrets := Secrets{}
+	nested := map[string]interface{}{"clientId": "id", "clientSecret": "s"}
+	data := map[string]interface{}{"name": "n", "client": nested}
+	secrets.hide(StateResource{Type: "test", Name: "one"}, &shape{}, data, "")
+	assert.Equal(t, Secrets{"test_one_client_client_secret": "s"}, secrets)
+	assert.Equal(t, "id", nested["clientId"])
+	assert.NotEqual(t, "s", nested["clientSecret"])
+	_, ok := data["other"]
+	assert.False(t, ok)
+}
+
+func TestHasSensitiveFields(t *testing.T) {
+	type leaf struct {
+		Secret string `json:"secret" sensitive:"true"`
+	}
+	type nested struct {
+		Leaf *leaf `json:"leaf"`
+	}
+	type recursive struct {
+		Name string     `json:"name"`
+		Next *recursive `json:"next"`
+	}
+	tests := map[string]struct {
+		Shape    interface{}
+		Expected bool
+	}{
+		"It finds top level sensitive fields":         {Shape: &leaf{}, Expected: true},
+		"It finds sensitive fields in nested structs": {Shape: &nested{}, Expected: true},
+		"It reports shapes without sensitive fields":  {Shape: &recursive{}},
+		"It reports shapes that aren't structs":       {Shape: map[string]interface{}{}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.Expected, HasSensitiveFields(test.Shape))
+		})
+	}
+}
@@ -1,13 +1,20 @@
 package stateparser
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
-	"github.com/onelogin/onelogin/terraform/importables"
 	"log"
 	"reflect"
+	"sort"
 	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/onelogin/onelogin-go-sdk/pkg/utils"
+	"github.com/onelogin/onelogin/terraform/importables"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // State is the in memory representation of tfstate.
@@ -18,6 +25,7 @@ type State struct {
 // Terraform resource representation
 type StateResource struct {
 	Content   []byte
+	Module    string             `json:"module,omitempty"`
 	Name      string             `json:"name"`
 	Type      string             `json:"type"`
 	Provider  string             `json:"provider"`
@@ -30,104 +38,289 @@ type ResourceInstance struct {
 }
 
 // takes the tfstate representations formats them as HCL and writes them to a bytes buffer
-// so it can be flushed into main.tf
-func ConvertTFStateToHCL(state State, importables *tfimportables.ImportableList) []byte {
-	var configBuilder strings.Builder
-	var resourceBuilder strings.Builder
-	providerSources := map[string]string{}
-
+// so it can be flushed into main.tf. Providers and attributes are written in sorted order, nested blocks after
+// attributes, so the same state always yields the same file, formatted as terraform fmt would.
+// Sensitive values are replaced with variables, declared at the end of the file, and returned to be kept elsewhere,
+// as are the files some attributes are read from.
+func ConvertTFStateToHCL(state State, importables *tfimportables.ImportableList) ([]byte, Secrets, Files, error) {
 	log.Println("Assembling main.tf...")
+	file := hclwrite.NewEmptyFile()
+	providersBody := file.Body().AppendNewBlock("terraform", nil).Body().AppendNewBlock("required_providers", nil).Body()
+	secrets, files := Secrets{}, Files{}
+	providerSources, err := appendResources(file.Body(), state, importables, secrets, files)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
+	providerKeys := make([]string, 0, len(providerSources))
+	for key := range providerSources {
+		providerKeys = append(providerKeys, key)
+	}
+	sort.Strings(providerKeys)
+	for _, key := range providerKeys {
+		providersBody.SetAttributeValue(key, cty.ObjectVal(map[string]cty.Value{"source": cty.StringVal(providerSources[key])}))
+	}
+	out := hclwrite.Format(file.Bytes())
+	if len(secrets) > 0 {
+		out = append(append(out, '\n'), secrets.VariablesHCL()...)
+	}
+	return out, secrets, files, nil
+}
+
+// ConvertTFStateToResourceHCL formats only the resources in state as HCL, without the required_providers block,
+// so they can be added to an existing file. Sensitive values are replaced with variables and returned, as are the
+// files some attributes are read from.
+func ConvertTFStateToResourceHCL(state State, importables *tfimportables.ImportableList) ([]byte, Secrets, Files, error) {
+	file := hclwrite.NewEmptyFile()
+	secrets, files := Secrets{}, Files{}
+	if _, err := appendResources(file.Body(), state, importables, secrets, files); err != nil {
+		return nil, nil, nil, err
+	}
+	return bytes.TrimLeft(hclwrite.Format(file.Bytes()), "\n"), secrets, files, nil
+}
+
+// appendResources writes a block for each resource instance in state, each after a blank line, and returns the
+// source of each provider they use by name. It fails on resources of a type no importable is registered for.
+func appendResources(body *hclwrite.Body, state State, importables *tfimportables.ImportableList, secrets Secrets, files Files) (map[string]string, error) {
+	providerSources := map[string]string{}
 	for _, resource := range state.Resources {
 		providerSource := strings.Replace(resource.Provider, `provider["`, "", 1)
 		providerSource = strings.Replace(providerSource, `"]`, "", 1)
 		providerSourceInfo := strings.Split(providerSource, "/")
-		providerSources[providerSourceInfo[1]] = strings.Join(providerSourceInfo[1:], "/")
+		providerSources[providerSourceInfo[len(providerSourceInfo)-1]] = strings.Join(providerSourceInfo[1:], "/")
 
-		for _, instance := range resource.Instances {
-			resourceBuilder.WriteString(fmt.Sprintf("resource %s %s {\n", resource.Type, resource.Name))
+		importable, err := importables.Importable(resource.Type)
+		if err != nil {
+			return nil, err
+		}
+		for i, instance := range resource.Instances {
 			b, _ := json.Marshal(instance.Data)
-			hclShape := importables.GetImportable(resource.Type).HCLShape()
+			hclShape := importable.HCLShape()
 			json.Unmarshal(b, hclShape)
-			convertToHCLLine(hclShape, 1, &resourceBuilder)
-			resourceBuilder.WriteString("}\n\n")
+			data, err := shapeToMap(hclShape)
+			if err != nil {
+				return nil, err
+			}
+			suffix := ""
+			if len(resource.Instances) > 1 {
+				suffix = fmt.Sprintf("_%d", i)
+			}
+			secrets.hide(resource, hclShape, data, suffix)
+			files.extract(resource, hclShape, data, suffix)
+			body.AppendNewline()
+			block := body.AppendNewBlock("resource", []string{resource.Type, resource.Name})
+			writeBody(block.Body(), data)
+		}
+		if len(resource.Content) > 0 {
+			content, diags := hclwrite.ParseConfig(resource.Content, resource.Name, hcl.InitialPos)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("unable to parse resource content: %s", diags)
+			}
+			body.AppendNewline()
+			body.AppendUnstructuredTokens(content.Body().BuildTokens(nil))
 		}
-		resourceBuilder.WriteString(string(resource.Content))
 	}
+	return providerSources, nil
+}
 
-	configBuilder.WriteString(fmt.Sprintf("terraform {\n\trequired_providers {\n"))
-	for key, provider := range providerSources {
-		configBuilder.WriteString(fmt.Sprintf("\t\t%s = {\n\t\t\tsource = \"%s\"\n\t\t}\n", key, provider))
+// shapeToMap converts the HCL shape to a map keyed by its json names, keeping numbers exact
+func shapeToMap(hclShape interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(hclShape)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse state to hcl: %s", err)
 	}
-	configBuilder.WriteString(fmt.Sprintf("\t}\n}\n"))
-	configBuilder.WriteString(resourceBuilder.String())
-
-	return []byte(configBuilder.String())
+	var m map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	decoder.Decode(&m)
+	return m, nil
 }
 
-func indent(level int) []byte {
-	out := make([]byte, level)
-	for i := 0; i < level; i++ {
-		out[i] = byte('\t')
+// writeBody writes the data to the body as attributes, then writes lists of objects as repeated nested blocks.
+// Null and empty values are left out.
+func writeBody(body *hclwrite.Body, data map[string]interface{}) {
+	blocks := []string{}
+	for _, key := range sortedKeys(data) {
+		name := utils.ToSnakeCase(key)
+		switch v := data[key].(type) {
+		case nil:
+		case hcl.Traversal:
+			body.SetAttributeTraversal(name, v)
+		case hclwrite.Tokens:
+			body.SetAttributeRaw(name, v)
+		case []interface{}:
+			if len(v) == 0 {
+				continue
+			}
+			if isBlockList(v) {
+				blocks = append(blocks, key)
+				continue
+			}
+			body.SetAttributeValue(name, ctyValue(v))
+		case map[string]interface{}:
+			if hasExpression(v) {
+				body.SetAttributeRaw(name, objectTokens(v))
+			} else if len(v) > 0 {
+				body.SetAttributeValue(name, ctyValue(v))
+			}
+		case string:
+			if isHeredoc(v) {
+				body.SetAttributeRaw(name, heredocTokens(v))
+			} else {
+				body.SetAttributeValue(name, cty.StringVal(v))
+			}
+		default:
+			body.SetAttributeValue(name, ctyValue(v))
+		}
+	}
+	for _, key := range blocks {
+		for _, element := range data[key].([]interface{}) {
+			if len(body.Attributes()) > 0 || len(body.Blocks()) > 0 {
+				body.AppendNewline()
+			}
+			writeBody(body.AppendNewBlock(utils.ToSnakeCase(key), nil).Body(), element.(map[string]interface{}))
+		}
 	}
-	return out
 }
 
-// recursively converts a chunk of data from it's struct representation to its HCL representation
-// and appends the "line" to a bytes buffer.
-func convertToHCLLine(input interface{}, indentLevel int, builder *strings.Builder) {
-	b, err := json.Marshal(input)
-	if err != nil {
-		log.Fatalln("unable to parse state to hcl")
+// objectTokens writes the object like ctyValue would, except that references to variables and other expressions in
+// it, such as those hiding its sensitive values, are kept
+func objectTokens(data map[string]interface{}) hclwrite.Tokens {
+	attrs := []hclwrite.ObjectAttrTokens{}
+	for _, key := range sortedKeys(data) {
+		var value hclwrite.Tokens
+		switch v := data[key].(type) {
+		case hcl.Traversal:
+			value = hclwrite.TokensForTraversal(v)
+		case hclwrite.Tokens:
+			value = v
+		case map[string]interface{}:
+			value = objectTokens(v)
+		default:
+			value = hclwrite.TokensForValue(ctyValue(v))
+		}
+		name := utils.ToSnakeCase(key)
+		nameTokens := hclwrite.TokensForValue(cty.StringVal(name))
+		if hclsyntax.ValidIdentifier(name) {
+			nameTokens = hclwrite.TokensForIdentifier(name)
+		}
+		attrs = append(attrs, hclwrite.ObjectAttrTokens{Name: nameTokens, Value: value})
 	}
-	var m map[string]interface{}
-	json.Unmarshal(b, &m)
-	for k, v := range m {
-		if v != nil {
-			switch reflect.TypeOf(v).Kind() {
-			case reflect.String:
-				builder.WriteString(fmt.Sprintf("%s%s = %q\n", indent(indentLevel), utils.ToSnakeCase(k), v))
-			case reflect.Int, reflect.Int32, reflect.Float32, reflect.Float64, reflect.Bool:
-				builder.WriteString(fmt.Sprintf("%s%s = %v\n", indent(indentLevel), utils.ToSnakeCase(k), v))
-			case reflect.Array, reflect.Slice:
-				sl := v.([]interface{})
-				if len(sl) > 0 {
-					switch reflect.TypeOf(sl[0]).Kind() { // array of complex stuff
-					case reflect.Array, reflect.Slice, reflect.Map:
-						for j := 0; j < len(sl); j++ {
-							builder.WriteString(strings.ToLower(fmt.Sprintf("\n%s%s {\n", indent(indentLevel), utils.ToSnakeCase(k))))
-							convertToHCLLine(sl[j], indentLevel+1, builder)
-							builder.WriteString(fmt.Sprintf("%s}\n", indent(indentLevel)))
-						}
-					case reflect.Int, reflect.Int32, reflect.Float32, reflect.Float64, reflect.Bool:
-						builder.WriteString(fmt.Sprintf("%s%s = [", indent(indentLevel), utils.ToSnakeCase(k)))
-						for j := 0; j < len(sl); j++ {
-							builder.WriteString(fmt.Sprintf("%0.f", sl[j])) // not really expecting decimal values in terraform but may require a fix later
-							if j < len(sl)-1 {
-								builder.WriteString(", ")
-							}
-						}
-						builder.WriteString("]\n")
-					default: // array of strings
-						builder.WriteString(fmt.Sprintf("%s%s = [", indent(indentLevel), utils.ToSnakeCase(k)))
-						for j := 0; j < len(sl); j++ {
-							builder.WriteString(fmt.Sprintf("%q", sl[j]))
-							if j < len(sl)-1 {
-								builder.WriteString(", ")
-							}
-						}
-						builder.WriteString("]\n")
-					}
-				}
-			case reflect.Map:
-				if len(v.(map[string]interface{})) > 0 {
-					builder.WriteString(strings.ToLower(fmt.Sprintf("\n%s%s = {\n", indent(indentLevel), utils.ToSnakeCase(k))))
-					convertToHCLLine(v, indentLevel+1, builder)
-					builder.WriteString(fmt.Sprintf("%s}\n", indent(indentLevel)))
-				}
-			default:
-				fmt.Println("Unable to Determine Type", k, v)
+	return hclwrite.TokensForObject(attrs)
+}
+
+// hasExpression reports whether the object holds a reference to a variable or another expression at any depth
+func hasExpression(data map[string]interface{}) bool {
+	for _, value := range data {
+		switch v := value.(type) {
+		case hcl.Traversal, hclwrite.Tokens:
+			return true
+		case map[string]interface{}:
+			if hasExpression(v) {
+				return true
 			}
 		}
 	}
+	return false
+}
+
+// isBlockList reports whether every element is an object, so the list is written as nested blocks
+func isBlockList(list []interface{}) bool {
+	for _, element := range list {
+		if _, ok := element.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ctyValue converts decoded JSON to the equivalent HCL value. Object keys are snake cased like attribute names.
+func ctyValue(data interface{}) cty.Value {
+	switch v := data.(type) {
+	case string:
+		return cty.StringVal(v)
+	case bool:
+		return cty.BoolVal(v)
+	case json.Number:
+		n, err := cty.ParseNumberVal(v.String())
+		if err != nil {
+			return cty.StringVal(v.String())
+		}
+		return n
+	case []interface{}:
+		if len(v) == 0 {
+			return cty.EmptyTupleVal
+		}
+		values := make([]cty.Value, len(v))
+		for i, element := range v {
+			values[i] = ctyValue(element)
+		}
+		return cty.TupleVal(values)
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return cty.EmptyObjectVal
+		}
+		values := map[string]cty.Value{}
+		for key, element := range v {
+			values[utils.ToSnakeCase(key)] = ctyValue(element)
+		}
+		return cty.ObjectVal(values)
+	default:
+		return cty.NullVal(cty.DynamicPseudoType)
+	}
+}
+
+// isHeredoc reports whether the string reads better as a heredoc. Heredocs always end in a newline, so only
+// strings that already do can be written as one without changing their value.
+func isHeredoc(s string) bool {
+	return strings.Count(s, "\n") > 1 && strings.HasSuffix(s, "\n")
+}
+
+// heredocTokens writes the string as a heredoc with template sequences escaped, so it is read back literally
+func heredocTokens(s string) hclwrite.Tokens {
+	delimiter := "EOT"
+	for strings.Contains(s, delimiter) {
+		delimiter += "T"
+	}
+	s = strings.ReplaceAll(s, "${", "$${")
+	s = strings.ReplaceAll(s, "%{", "%%{")
+	return hclwrite.Tokens{
+		{Type: hclsyntax.TokenOHeredoc, Bytes: []byte("<<" + delimiter + "\n")},
+		{Type: hclsyntax.TokenStringLit, Bytes: []byte(s)},
+		{Type: hclsyntax.TokenCHeredoc, Bytes: []byte(delimiter)},
+	}
+}
+
+// taggedFields returns the value of the tag on each field of the HCL shape that has it, by the field's json name
+func taggedFields(hclShape interface{}, tag string) map[string]string {
+	fields := map[string]string{}
+	shape := reflect.TypeOf(hclShape)
+	if shape.Kind() == reflect.Ptr {
+		shape = shape.Elem()
+	}
+	if shape.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < shape.NumField(); i++ {
+		field := shape.Field(i)
+		value, ok := field.Tag.Lookup(tag)
+		if !ok {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = value
+	}
+	return fields
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
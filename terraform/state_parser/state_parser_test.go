@@ -1,15 +1,24 @@
 package stateparser
 
 import (
-	"fmt"
-
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/onelogin/onelogin/clients"
 	tfimportables "github.com/onelogin/onelogin/terraform/importables"
 	"github.com/stretchr/testify/assert"
 )
 
+// update rewrites the golden files from the current output: go test ./terraform/state_parser -update
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
 func TestConvertTFStateToHCL(t *testing.T) {
 	tests := map[string]struct {
 		InputState     State
@@ -75,7 +84,49 @@ func TestConvertTFStateToHCL(t *testing.T) {
 					},
 				},
 			},
-			ExpectedOutput: fmt.Sprintf("terraform {\n\trequired_providers {\n\t\tonelogin = {\n\t\t\tsource = \"onelogin/onelogin\"\n\t\t}\n\t\taws = {\n\t\t\tsource = \"aws/aws\"\n\t\t}\n\t}\n}\nresource onelogin_apps test_resource {\n\n\tprovisioning = {\n\t\tenabled = true\n\t}\n\n\trules {\n\n\t\tactions {\n\t\t\tvalue = [\"member_of\", \"asdf\"]\n\t\t}\n\t}\n\tconnector_id = 22\n\tname = \"test\"\n\n\tconfiguration = {\n\t\tprovider_arn = \"arn\"\n\t\tsignature_algorithm = \"sha-256\"\n\t}\n}\n\nresource onelogin_roles test_resource {\n\tname = \"test\"\n\tapps = [1, 2, 3]\n}\n\nresource onelogin_users test_resource {\n\tusername = \"test\"\n\temail = \"test@test.test\"\n}\n\nresource aws_iam_user test_resource {\n\tpath = \"/\"\n}\n\n"),
+			ExpectedOutput: `terraform {
+  required_providers {
+    aws = {
+      source = "aws/aws"
+    }
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "onelogin_apps" "test_resource" {
+  configuration = {
+    provider_arn        = "arn"
+    signature_algorithm = "sha-256"
+  }
+  connector_id = 22
+  name         = "test"
+  provisioning = {
+    enabled = true
+  }
+
+  rules {
+    actions {
+      value = ["member_of", "asdf"]
+    }
+  }
+}
+
+resource "onelogin_roles" "test_resource" {
+  apps = [1, 2, 3]
+  name = "test"
+}
+
+resource "onelogin_users" "test_resource" {
+  email    = "test@test.test"
+  username = "test"
+}
+
+resource "aws_iam_user" "test_resource" {
+  path = "/"
+}
+`,
 		},
 	}
 	for name, test := range tests {
@@ -89,8 +140,178 @@ func TestConvertTFStateToHCL(t *testing.T) {
 				},
 			}
 			importables := tfimportables.New(&clients)
-			actual := ConvertTFStateToHCL(test.InputState, importables)
-			assert.Equal(t, len(test.ExpectedOutput), len(string(actual)))
+			actual, _, _, err := ConvertTFStateToHCL(test.InputState, importables)
+			assert.Nil(t, err)
+			assert.Equal(t, test.ExpectedOutput, string(actual))
+		})
+	}
+}
+
+func TestConvertTFStateToResourceHCL(t *testing.T) {
+	state := State{
+		Resources: []StateResource{
+			{
+				Name:      "admins",
+				Type:      "onelogin_roles",
+				Provider:  "provider[\"registry.terraform.io/onelogin/onelogin\"]",
+				Instances: []ResourceInstance{{Data: map[string]interface{}{"name": "Admins"}}},
+			},
+			{
+				Name:      "users",
+				Type:      "onelogin_roles",
+				Provider:  "provider[\"registry.terraform.io/onelogin/onelogin\"]",
+				Instances: []ResourceInstance{{Data: map[string]interface{}{"name": "Users"}}},
+			},
+		},
+	}
+	importables := tfimportables.New(&clients.Clients{
+		ClientConfigs: clients.ClientConfigs{
+			OneLoginClientID:     "ONELOGIN_CLIENT_ID",
+			OneLoginClientSecret: "ONELOGIN_CLIENT_SECRET",
+			OneLoginURL:          "ONELOGIN_OAPI_URL",
+		},
+	})
+	expected := `resource "onelogin_roles" "admins" {
+  name = "Admins"
+}
+
+resource "onelogin_roles" "users" {
+  name = "Users"
+}
+`
+	actual, secrets, _, err := ConvertTFStateToResourceHCL(state, importables)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(actual))
+	assert.Empty(t, secrets)
+}
+
+func TestConvertTFStateToHCLErrors(t *testing.T) {
+	tests := map[string]struct {
+		InputState State
+	}{
+		"It returns an error for resources of an unknown type": {
+			InputState: State{Resources: []StateResource{
+				{Name: "x", Type: "onelogin_widgets", Provider: "provider[\"registry.terraform.io/onelogin/onelogin\"]", Instances: []ResourceInstance{{Data: map[string]interface{}{}}}},
+			}},
+		},
+		"It returns an error for resource content that isn't HCL": {
+			InputState: State{Resources: []StateResource{
+				{Name: "x", Type: "onelogin_roles", Provider: "provider[\"registry.terraform.io/onelogin/onelogin\"]", Content: []byte("resource {")},
+			}},
+		},
+	}
+	importables := tfimportables.New(&clients.Clients{
+		ClientConfigs: clients.ClientConfigs{
+			OneLoginClientID:     "ONELOGIN_CLIENT_ID",
+			OneLoginClientSecret: "ONELOGIN_CLIENT_SECRET",
+			OneLoginURL:          "ONELOGIN_OAPI_URL",
+		},
+	})
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, _, err := ConvertTFStateToHCL(test.InputState, importables)
+			assert.Error(t, err)
+			_, _, _, err = ConvertTFStateToResourceHCL(test.InputState, importables)
+			assert.Error(t, err)
 		})
 	}
 }
+
+// TestConvertTFStateToHCLGolden converts the state of each importable in testdata/<name>.tfstate and compares it
+// with testdata/<name>.golden.tf, its sensitive values with testdata/<name>.golden.tfvars and the files it reads
+// attributes from with those in testdata/<name>.golden/
+func TestConvertTFStateToHCLGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.tfstate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientList := &clients.Clients{
+		ClientConfigs: clients.ClientConfigs{
+			OneLoginClientID:     "ONELOGIN_CLIENT_ID",
+			OneLoginClientSecret: "ONELOGIN_CLIENT_SECRET",
+			OneLoginURL:          "ONELOGIN_OAPI_URL",
+			OktaOrgName:          "test",
+			OktaBaseURL:          "test.com",
+			OktaAPIToken:         "test",
+			AwsRegion:            "us-west-2",
+		},
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".tfstate")
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := State{}
+			if err := json.Unmarshal(data, &state); err != nil {
+				t.Fatal(err)
+			}
+			actual, secrets, files, err := ConvertTFStateToHCL(state, tfimportables.New(clientList))
+			assert.Nil(t, err)
+			actualTFVars, err := secrets.TFVars(nil)
+			assert.Nil(t, err)
+			golden := filepath.Join("testdata", name+".golden.tf")
+			goldenTFVars := filepath.Join("testdata", name+".golden.tfvars")
+			if *update {
+				ioutil.WriteFile(golden, actual, 0600)
+				if len(secrets) > 0 {
+					ioutil.WriteFile(goldenTFVars, actualTFVars, 0600)
+				}
+			}
+			expected, err := ioutil.ReadFile(golden)
+			assert.Nil(t, err)
+			assert.Equal(t, string(expected), string(actual))
+			_, diags := hclparse.NewParser().ParseHCL(actual, golden)
+			assert.False(t, diags.HasErrors(), diags.Error())
+			if expectedTFVars, err := ioutil.ReadFile(goldenTFVars); err == nil || len(secrets) > 0 {
+				assert.Equal(t, string(expectedTFVars), string(actualTFVars))
+			}
+			goldenDir := filepath.Join("testdata", name+".golden")
+			if *update {
+				os.RemoveAll(goldenDir)
+			}
+			for filePath, content := range files {
+				goldenFile := filepath.Join(goldenDir, filePath)
+				if *update {
+					os.MkdirAll(filepath.Dir(goldenFile), 0750)
+					ioutil.WriteFile(goldenFile, content, 0600)
+				}
+				expected, err := ioutil.ReadFile(goldenFile)
+				assert.Nil(t, err)
+				assert.Equal(t, string(expected), string(content))
+			}
+			assert.Equal(t, goldenFiles(t, goldenDir), fileNames(files), "files written should match those in "+goldenDir)
+		})
+	}
+}
+
+// goldenFiles lists the files under dir by their slash separated path relative to it. A missing dir has none.
+func goldenFiles(t *testing.T, dir string) []string {
+	names := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		names = append(names, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fileNames(files Files) []string {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
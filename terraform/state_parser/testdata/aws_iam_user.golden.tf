@@ -0,0 +1,12 @@
+terraform {
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+
+resource "aws_iam_user" "jane" {
+  name = "jane"
+  path = "/engineering/"
+}
@@ -0,0 +1,70 @@
+terraform {
+  required_providers {
+    okta = {
+      source = "oktadeveloper/okta"
+    }
+  }
+}
+
+resource "okta_app_saml" "okta-app-0oa1" {
+  features     = ["PUSH_NEW_USERS", "IMPORT_USER_SCHEMA"]
+  id           = "0oa1"
+  label        = "Workday"
+  name         = "workday"
+  sign_on_mode = "SAML_2_0"
+  status       = "ACTIVE"
+  visibility = {
+    auto_submit_toolbar = false
+    hide = {
+      i_os = false
+      web  = false
+    }
+  }
+}
+
+resource "okta_app_oauth" "okta-app-0oa2" {
+  credentials = {
+    oauth_client = {
+      auto_key_rotation          = true
+      client_id                  = "0oa2client"
+      client_secret              = var.okta_app_oauth_okta-app-0oa2_credentials_oauth_client_client_secret
+      token_endpoint_auth_method = "client_secret_basic"
+    }
+    signing = {
+      kid = "kid-1"
+    }
+    user_name_template = {
+      template = "$${source.login}"
+      type     = "BUILT_IN"
+    }
+  }
+  id           = "0oa2"
+  label        = "Portal"
+  name         = "oidc_client"
+  sign_on_mode = "OPENID_CONNECT"
+  status       = "ACTIVE"
+}
+
+resource "okta_app_basic_auth" "okta-app-0oa3" {
+  credentials = {
+    password = {
+      value = var.okta_app_basic_auth_okta-app-0oa3_credentials_password_value
+    }
+    reveal_password = false
+    scheme          = "SHARED_USERNAME_AND_PASSWORD"
+    user_name       = "shared"
+  }
+  id           = "0oa3"
+  label        = "Intranet"
+  name         = "template_basic_auth"
+  sign_on_mode = "BASIC_AUTH"
+  status       = "ACTIVE"
+}
+
+variable "okta_app_basic_auth_okta-app-0oa3_credentials_password_value" {
+  sensitive = true
+}
+
+variable "okta_app_oauth_okta-app-0oa2_credentials_oauth_client_client_secret" {
+  sensitive = true
+}
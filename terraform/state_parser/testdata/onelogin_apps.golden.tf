@@ -0,0 +1,47 @@
+terraform {
+  required_providers {
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "onelogin_apps" "salesforce-1234" {
+  allow_assumed_signin = false
+  configuration = {
+    signature_algorithm = "SHA-256"
+  }
+  connector_id = 108419
+  description  = "CRM"
+  name         = "Salesforce"
+  notes        = ""
+  provisioning = {
+    enabled = false
+  }
+  visible = true
+
+  parameters {
+    id                        = 1
+    include_in_saml_assertion = true
+    label                     = "Email"
+    param_key_name            = "email"
+    user_attribute_mappings   = "email"
+  }
+
+  rules {
+    enabled = true
+    match   = "all"
+    name    = "Admins"
+
+    actions {
+      action = "set_role"
+      value  = ["admin", "user"]
+    }
+
+    conditions {
+      operator = "ri"
+      source   = "has_role"
+      value    = "123"
+    }
+  }
+}
@@ -0,0 +1,18 @@
+terraform {
+  required_providers {
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "onelogin_oidc_apps" "portal-9012" {
+  configuration = {
+    oidc_application_type      = "0"
+    redirect_uri               = "https://portal.example.com/callback"
+    token_endpoint_auth_method = "1"
+  }
+  connector_id = 108419
+  name         = "Portal"
+  visible      = true
+}
@@ -0,0 +1,18 @@
+terraform {
+  required_providers {
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "onelogin_roles" "admins-1" {
+  admins = [11]
+  apps   = [1234, 5678]
+  name   = "Admins"
+  users  = [11, 12]
+}
+
+resource "onelogin_roles" "empty-2" {
+  name = "Empty"
+}
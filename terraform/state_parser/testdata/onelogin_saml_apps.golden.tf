@@ -0,0 +1,17 @@
+terraform {
+  required_providers {
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "onelogin_saml_apps" "aws-multi-account-5678" {
+  configuration = {
+    provider_arn        = "arn:aws:iam::123456789012:saml-provider/OneLogin"
+    signature_algorithm = "SHA-1"
+  }
+  connector_id = 50534
+  name         = "AWS Multi Account"
+  visible      = true
+}
@@ -0,0 +1,19 @@
+terraform {
+  required_providers {
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "onelogin_smarthook_environment_variables" "api_key-e1" {
+  created_at = "2021-02-01T00:00:00Z"
+  id         = "e1"
+  name       = "API_KEY"
+  updated_at = "2021-02-01T00:00:00Z"
+  value      = var.onelogin_smarthook_environment_variables_api_key-e1_value
+}
+
+variable "onelogin_smarthook_environment_variables_api_key-e1_value" {
+  sensitive = true
+}
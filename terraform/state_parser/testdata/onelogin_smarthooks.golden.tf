@@ -0,0 +1,29 @@
+terraform {
+  required_providers {
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "onelogin_smarthooks" "pre-authentication-abc" {
+  context_version = "1.1.0"
+  disabled        = false
+  env_vars        = ["API_KEY"]
+  function        = base64encode(file("${path.module}/hooks/pre-authentication-abc.js"))
+  id              = "abc"
+  options = {
+    location_enabled        = true
+    mfa_device_info_enabled = false
+    risk_enabled            = false
+  }
+  packages = {
+    "@scope/helper" = "1.0.0"
+    mysql           = "^2.18.1"
+  }
+  retries = 0
+  runtime = "nodejs12.x"
+  status  = "ready"
+  timeout = 1
+  type    = "pre-authentication"
+}
@@ -0,0 +1,4 @@
+exports.handler = async (context) => {
+  console.log(`user ${context.user.user_identifier}`);
+  return { success: true, user: { policy_id: context.user.policy_id } };
+};
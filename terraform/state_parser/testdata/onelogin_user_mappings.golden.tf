@@ -0,0 +1,31 @@
+terraform {
+  required_providers {
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "onelogin_user_mappings" "engineering-7" {
+  enabled  = true
+  match    = "any"
+  name     = "Engineering"
+  position = 2
+
+  actions {
+    action = "add_role"
+    value  = ["123"]
+  }
+
+  conditions {
+    operator = "="
+    source   = "department"
+    value    = "Engineering"
+  }
+
+  conditions {
+    operator = "~"
+    source   = "title"
+    value    = "Engineer"
+  }
+}
@@ -0,0 +1,18 @@
+terraform {
+  required_providers {
+    onelogin = {
+      source = "onelogin/onelogin"
+    }
+  }
+}
+
+resource "onelogin_users" "jane-42" {
+  email     = "jane@example.com"
+  firstname = "Jane"
+  group_id  = 123456
+  lastname  = "Doe"
+  state     = 1
+  status    = 1
+  title     = "Engineer \"Platform\""
+  username  = "jane"
+}